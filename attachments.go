@@ -0,0 +1,408 @@
+package pouchdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/flimzy/jsblob"
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/gopherjs/jsbuiltin"
+)
+
+// Attachment represents document attachments.
+// This structure is borrowed from http://godoc.org/github.com/fjl/go-couchdb#Attachment
+// Body is an io.ReadCloser, so that bodies read from PouchDB can be
+// released; readers without a Close method may be wrapped with
+// ioutil.NopCloser.
+type Attachment struct {
+	Name string        // File name
+	Type string        // MIME type of the Body
+	MD5  []byte        // MD5 checksum of the Body
+	Body io.ReadCloser // The body itself
+
+	// length is the size of the body, if known from its stub.
+	length int64
+}
+
+// Close closes the attachment's Body, if any. It should be called once the
+// Body of an attachment fetched with Attachment() or AttachmentWithOpts() is
+// no longer needed.
+func (a *Attachment) Close() error {
+	if a.Body == nil {
+		return nil
+	}
+	return a.Body.Close()
+}
+
+// AttachmentOptions represents the optional configuration options for
+// AttachmentWithOpts().
+type AttachmentOptions struct {
+	// Fetch the attachment from a specific revision of the document.
+	// Defaults to the winning revision.
+	Rev string
+
+	// Verify the body against the MD5 digest stored with the attachment, as
+	// it is read. If they don't match, reading the end of the Body returns an
+	// error wrapping ErrDigestMismatch, instead of io.EOF.
+	Verify bool
+}
+
+// ErrDigestMismatch is returned, wrapped, when a verified attachment's body
+// does not match its stored digest.
+var ErrDigestMismatch = errors.New("attachment digest mismatch")
+
+// ErrNoDigest is returned, wrapped, when verification of an attachment is
+// requested, but it has no stored digest to verify against.
+var ErrNoDigest = errors.New("no attachment digest available")
+
+// AttachmentStub describes an attachment, as stored in a document's
+// _attachments field, without its body.
+type AttachmentStub struct {
+	// Name is the attachment's file name.
+	Name        string `json:"-"`
+	ContentType string `json:"content_type"`
+	// Digest is the checksum of the body, in the form "md5-<base64 hash>".
+	Digest string `json:"digest"`
+	// Length is the size of the body, in bytes.
+	Length int64 `json:"length"`
+	// RevPos is the revision number of the document in which the attachment
+	// was last changed.
+	RevPos int `json:"revpos"`
+}
+
+// MD5 returns the MD5 checksum encoded in the stub's digest, or nil if the
+// digest is not an MD5 digest.
+func (s *AttachmentStub) MD5() []byte {
+	sum, _ := parseDigest(s.Digest)
+	return sum
+}
+
+const md5Prefix = "md5-"
+
+// parseDigest decodes a digest of the form "md5-<base64 hash>".
+func parseDigest(digest string) ([]byte, error) {
+	if !strings.HasPrefix(digest, md5Prefix) {
+		return nil, fmt.Errorf("unsupported digest '%s'", digest)
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimPrefix(digest, md5Prefix))
+}
+
+// formatDigest encodes an MD5 checksum as a digest.
+func formatDigest(sum []byte) string {
+	return md5Prefix + base64.StdEncoding.EncodeToString(sum)
+}
+
+// Attachments holds the inline attachments of a document, keyed by name. Add
+// a field of this type, tagged `json:"_attachments,omitempty"`, to a document
+// struct to create or update its attachments in the same revision as the
+// document itself, with Put() or BulkDocs().
+//
+// Attachments with a Body are sent as base64-encoded inline data. The Body is
+// read to the end, closed, and replaced with an
+// in-memory copy of the data, so marshalling the document again, such as
+// when retrying after a conflict, sends the same data. Attachments without a
+// Body are sent as stubs, which leave the stored attachment of that name
+// unchanged; PouchDB identifies stubs by their digest, so MD5 must be set.
+// Attachments missing from the map are removed from the document.
+//
+// When a document is read with the Attachments option set, each attachment's
+// Body holds its decoded data. Otherwise only stubs are returned, with a nil
+// Body. In both cases Type and MD5 are filled in, so the attachments may be
+// written back unchanged.
+type Attachments map[string]*Attachment
+
+// inlineAttachment is the JSON representation of an attachment within a
+// document's _attachments field, as read from PouchDB. encoding/json takes
+// care of the base64 decoding of Data.
+type inlineAttachment struct {
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+	Digest      string `json:"digest"`
+	Length      int64  `json:"length"`
+	Stub        bool   `json:"stub"`
+}
+
+// attachmentData is the Body of an attachment whose data is held in memory,
+// either because it was decoded from a document, or because MarshalJSON read
+// the original Body. MarshalJSON sends all of data, however much of the Body
+// has been read.
+type attachmentData struct {
+	*bytes.Reader
+	data []byte
+}
+
+func newAttachmentData(data []byte) *attachmentData {
+	return &attachmentData{Reader: bytes.NewReader(data), data: data}
+}
+
+// Close satisfies the io.Closer interface. There is nothing to release.
+func (d *attachmentData) Close() error {
+	return nil
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (a Attachments) MarshalJSON() ([]byte, error) {
+	inline := make(map[string]map[string]interface{}, len(a))
+	for name, att := range a {
+		if att == nil {
+			continue
+		}
+		ia := make(map[string]interface{})
+		if att.Type != "" {
+			ia["content_type"] = att.Type
+		}
+		switch body := att.Body.(type) {
+		case nil:
+			if len(att.MD5) == 0 {
+				return nil, fmt.Errorf("attachment %s: a stub needs an MD5 digest", name)
+			}
+			ia["stub"] = true
+			ia["digest"] = formatDigest(att.MD5)
+			if att.length > 0 {
+				ia["length"] = att.length
+			}
+		case *attachmentData:
+			// encoding/json encodes []byte as base64
+			ia["data"] = body.data
+		default:
+			data, err := ioutil.ReadAll(body)
+			if err != nil {
+				return nil, fmt.Errorf("attachment %s: %w", name, err)
+			}
+			if err := body.Close(); err != nil {
+				return nil, fmt.Errorf("attachment %s: %w", name, err)
+			}
+			att.Body = newAttachmentData(data)
+			ia["data"] = data
+		}
+		inline[name] = ia
+	}
+	return json.Marshal(inline)
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+func (a *Attachments) UnmarshalJSON(data []byte) error {
+	var inline map[string]*inlineAttachment
+	if err := json.Unmarshal(data, &inline); err != nil {
+		return err
+	}
+	atts := make(Attachments, len(inline))
+	for name, ia := range inline {
+		stub := &AttachmentStub{Digest: ia.Digest}
+		att := &Attachment{
+			Name:   name,
+			Type:   ia.ContentType,
+			MD5:    stub.MD5(),
+			length: ia.Length,
+		}
+		if !ia.Stub {
+			att.Body = newAttachmentData(ia.Data)
+		}
+		atts[name] = att
+	}
+	*a = atts
+	return nil
+}
+
+// Attachments lists the attachments of a document, sorted by name, without
+// fetching their bodies. The rev argument can be left empty to use the latest
+// revision.
+func (db *PouchDB) Attachments(docid, rev string) ([]*AttachmentStub, error) {
+	return db.AttachmentsContext(context.Background(), docid, rev)
+}
+
+// AttachmentsContext is like Attachments, but accepts a context.
+func (db *PouchDB) AttachmentsContext(ctx context.Context, docid, rev string) ([]*AttachmentStub, error) {
+	stubs, _, err := db.attachmentStubs(ctx, docid, rev)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*AttachmentStub, 0, len(stubs))
+	for _, stub := range stubs {
+		list = append(list, stub)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// attachmentStubs fetches the attachment stubs of the requested revision of
+// a document, keyed by name, along with the revision's ID.
+func (db *PouchDB) attachmentStubs(ctx context.Context, docid, rev string) (map[string]*AttachmentStub, string, error) {
+	var doc struct {
+		Rev         string                     `json:"_rev"`
+		Attachments map[string]*AttachmentStub `json:"_attachments"`
+	}
+	if err := db.GetContext(ctx, docid, &doc, GetOptions{Rev: rev}); err != nil {
+		return nil, "", err
+	}
+	for name, stub := range doc.Attachments {
+		stub.Name = name
+	}
+	return doc.Attachments, doc.Rev, nil
+}
+
+// PutAttachment creates or updates an attachment. To create an attachment
+// on a non-existing document, pass an empty rev.
+//
+// See http://pouchdb.com/api.html#save_attachment and
+// http://godoc.org/github.com/fjl/go-couchdb#DB.PutAttachment
+func (db *PouchDB) PutAttachment(docid string, att *Attachment, rev string) (newrev string, err error) {
+	return db.PutAttachmentContext(context.Background(), docid, att, rev)
+}
+
+// PutAttachmentContext is like PutAttachment, but accepts a context.
+func (db *PouchDB) PutAttachmentContext(ctx context.Context, docid string, att *Attachment, rev string) (newrev string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	body, err := attachmentObject(att)
+	if err != nil {
+		return "", err
+	}
+	rw := NewResultWaiterContext(ctx)
+	db.Call("putAttachment", docid, att.Name, rev, body, att.Type, rw.Done)
+	return rw.ReadRev()
+}
+
+// isNode returns true if the Node.js Buffer type is available.
+func isNode() bool {
+	return jsbuiltin.TypeOf(js.Global.Get("Buffer")) == "function"
+}
+
+// bufferFromBytes copies b into a new Node.js Buffer.
+func bufferFromBytes(b []byte) *js.Object {
+	buffer := js.Global.Get("Buffer")
+	if jsbuiltin.TypeOf(buffer.Get("from")) == "function" {
+		return buffer.Call("from", b)
+	}
+	// Node < 4.5
+	return buffer.New(b)
+}
+
+// bufferBytes returns a byte slice sharing the memory of a Node.js Buffer,
+// or any other Uint8Array.
+func bufferBytes(buf *js.Object) []byte {
+	// Buffer is a subclass of Uint8Array, but GopherJS only converts plain
+	// Uint8Arrays to []byte, so create a plain view of the same memory.
+	view := js.Global.Get("Uint8Array").New(buf.Get("buffer"), buf.Get("byteOffset"), buf.Get("length"))
+	return view.Interface().([]byte)
+}
+
+// attachmentFromPouch wraps the Buffer or Blob returned by PouchDB in an
+// Attachment, whose Body reads from it.
+func attachmentFromPouch(name string, obj *js.Object) *Attachment {
+	att := &Attachment{
+		Name: name,
+	}
+	if jsbuiltin.TypeOf(obj.Get("write")) == "function" {
+		// This looks like a Buffer object; we're in node
+		att.Body = &bufferBody{buf: bufferBytes(obj)}
+	} else {
+		// We're in the browser
+		att.Type = obj.Get("type").String()
+		blob := &jsblob.Blob{*obj}
+		att.Body = &blobBody{blob: blob, size: blob.Size()}
+	}
+	return att
+}
+
+// Attachment retrieves an attachment. The rev argument can be left empty to
+// retrieve the latest revision. The caller is responsible for closing the
+// attachment with its Close method, if the returned error is nil.
+// The Body is read from PouchDB's Buffer or Blob in chunks, rather than being
+// copied up front.
+//
+// PouchDB's getAttachment() does not return the content type in Node, nor
+// the MD5 sum. Use AttachmentWithOpts with Verify set, or Attachments(), to
+// get them from the attachment's stub.
+//
+// See http://pouchdb.com/api.html#get_attachment and
+// http://godoc.org/github.com/fjl/go-couchdb#Attachment
+func (db *PouchDB) Attachment(docid, name, rev string) (*Attachment, error) {
+	return db.AttachmentContext(context.Background(), docid, name, rev)
+}
+
+// AttachmentContext is like Attachment, but accepts a context.
+func (db *PouchDB) AttachmentContext(ctx context.Context, docid, name, rev string) (*Attachment, error) {
+	return db.AttachmentWithOptsContext(ctx, docid, name, AttachmentOptions{Rev: rev})
+}
+
+// AttachmentWithOpts retrieves an attachment, like Attachment, with
+// additional options. With Verify set, the content type and MD5 sum are
+// filled in from the attachment's stub, and an error wrapping ErrNoDigest is
+// returned if the stub has no digest to verify against.
+func (db *PouchDB) AttachmentWithOpts(docid, name string, opts AttachmentOptions) (*Attachment, error) {
+	return db.AttachmentWithOptsContext(context.Background(), docid, name, opts)
+}
+
+// AttachmentWithOptsContext is like AttachmentWithOpts, but accepts a
+// context.
+func (db *PouchDB) AttachmentWithOptsContext(ctx context.Context, docid, name string, opts AttachmentOptions) (*Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rev := opts.Rev
+	var stub *AttachmentStub
+	if opts.Verify {
+		// The stub is fetched from the same revision as the body, so the
+		// digest matches.
+		stubs, stubRev, err := db.attachmentStubs(ctx, docid, opts.Rev)
+		if err != nil {
+			return nil, err
+		}
+		stub = stubs[name]
+		if stub == nil || stub.Digest == "" {
+			return nil, fmt.Errorf("attachment %s: %w", name, ErrNoDigest)
+		}
+		rev = stubRev
+	}
+	getOpts := map[string]interface{}{}
+	if rev != "" {
+		getOpts["rev"] = rev
+	}
+	rw := NewResultWaiterContext(ctx)
+	db.Call("getAttachment", docid, name, getOpts, rw.Done)
+	obj, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	att := attachmentFromPouch(name, obj)
+	if stub == nil {
+		return att, nil
+	}
+	if att.Type == "" {
+		att.Type = stub.ContentType
+	}
+	att.MD5 = stub.MD5()
+	att.length = stub.Length
+	body, err := newDigestBody(att.Body, stub.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("attachment %s: %w", name, err)
+	}
+	att.Body = body
+	return att, nil
+}
+
+func (db *PouchDB) DeleteAttachment(docid, name, rev string) (newrev string, err error) {
+	return db.DeleteAttachmentContext(context.Background(), docid, name, rev)
+}
+
+// DeleteAttachmentContext is like DeleteAttachment, but accepts a context.
+func (db *PouchDB) DeleteAttachmentContext(ctx context.Context, docid, name, rev string) (newrev string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	rw := NewResultWaiterContext(ctx)
+	db.Call("removeAttachment", docid, name, rev, rw.Done)
+	return rw.ReadRev()
+}
@@ -0,0 +1,204 @@
+package pouchdb
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/flimzy/jsblob"
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// attachmentChunkSize is the size of the chunks in which attachment bodies
+// are copied between Go and JavaScript.
+const attachmentChunkSize = 64 * 1024
+
+var errBodyClosed = errors.New("read on closed attachment body")
+
+// attachmentObject copies att.Body to a JavaScript Buffer in node, or a Blob
+// in the browser. PouchDB needs the whole body at once, so it is held in
+// memory either way. In node, a body whose size is known, because it is an
+// io.Seeker, is read straight into a single Buffer. Otherwise the body is
+// read in chunks, which are then concatenated, or passed to the Blob
+// constructor, which copies them; up to twice the size of the body is then
+// held in memory until the copy is made.
+func attachmentObject(att *Attachment) (*js.Object, error) {
+	if isNode() {
+		if size, ok := remaining(att.Body); ok {
+			// The size is known, so read straight into a Buffer
+			buf := allocBuffer(size)
+			if _, err := io.ReadFull(att.Body, bufferBytes(buf)); err != nil {
+				return nil, err
+			}
+			return buf, nil
+		}
+	}
+	var parts []interface{}
+	chunk := make([]byte, attachmentChunkSize)
+	for {
+		n, err := att.Body.Read(chunk)
+		if n > 0 {
+			if isNode() {
+				// Buffer.from() copies, so the chunk can be reused
+				parts = append(parts, bufferFromBytes(chunk[:n]))
+			} else {
+				// The Blob constructor copies the parts only once they are
+				// all read, so each part needs its own memory
+				parts = append(parts, chunk[:n])
+				chunk = make([]byte, attachmentChunkSize)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if isNode() {
+		return js.Global.Get("Buffer").Call("concat", parts), nil
+	}
+	return js.Global.Get("Blob").New(parts, map[string]string{"type": att.Type}), nil
+}
+
+// remaining returns the number of bytes left to read from r, if r is an
+// io.Seeker, such as an *os.File or *bytes.Reader.
+func remaining(r io.Reader) (int, bool) {
+	s, ok := r.(io.Seeker)
+	if !ok {
+		return 0, false
+	}
+	cur, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false
+	}
+	if _, err := s.Seek(cur, io.SeekStart); err != nil {
+		return 0, false
+	}
+	return int(end - cur), true
+}
+
+// allocBuffer allocates a Node.js Buffer of the given size.
+func allocBuffer(size int) *js.Object {
+	buffer := js.Global.Get("Buffer")
+	if buffer.Get("allocUnsafe") != js.Undefined {
+		return buffer.Call("allocUnsafe", size)
+	}
+	// Node < 4.5
+	return buffer.New(size)
+}
+
+// bufferBody is the Body of an attachment read in node. It reads directly
+// from the memory of the Buffer returned by PouchDB.
+type bufferBody struct {
+	buf    []byte
+	closed bool
+}
+
+var _ io.ReadCloser = &bufferBody{}
+
+func (b *bufferBody) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, errBodyClosed
+	}
+	if len(b.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
+
+// Close releases the Buffer.
+func (b *bufferBody) Close() error {
+	b.buf = nil
+	b.closed = true
+	return nil
+}
+
+// blobBody is the Body of an attachment read in the browser. It reads the
+// Blob returned by PouchDB in slices of at most attachmentChunkSize bytes.
+type blobBody struct {
+	blob   *jsblob.Blob
+	offset int
+	size   int
+}
+
+var _ io.ReadCloser = &blobBody{}
+
+func (b *blobBody) Read(p []byte) (int, error) {
+	if b.blob == nil {
+		return 0, errBodyClosed
+	}
+	if b.offset >= b.size {
+		return 0, io.EOF
+	}
+	end := b.offset + len(p)
+	if len(p) > attachmentChunkSize {
+		end = b.offset + attachmentChunkSize
+	}
+	if end > b.size {
+		end = b.size
+	}
+	n := copy(p, b.blob.Slice(b.offset, end, "").Bytes())
+	b.offset += n
+	return n, nil
+}
+
+// Close releases the Blob.
+func (b *blobBody) Close() error {
+	b.blob = nil
+	return nil
+}
+
+// digestBody verifies a body against its digest as it is read. Instead of
+// io.EOF, the final Read returns an error wrapping ErrDigestMismatch if the
+// body does not match.
+type digestBody struct {
+	io.ReadCloser
+	hash   hash.Hash
+	digest string
+}
+
+var _ io.ReadCloser = &digestBody{}
+
+func newDigestBody(body io.ReadCloser, digest string) (*digestBody, error) {
+	if _, err := parseDigest(digest); err != nil {
+		return nil, err
+	}
+	return &digestBody{
+		ReadCloser: body,
+		hash:       md5.New(),
+		digest:     digest,
+	}, nil
+}
+
+func (b *digestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		if verr := checkDigest(b.hash.Sum(nil), b.digest); verr != nil {
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+// checkDigest compares an MD5 checksum against a digest of the form
+// "md5-<base64 hash>".
+func checkDigest(sum []byte, digest string) error {
+	expected, err := parseDigest(digest)
+	if err != nil {
+		return err
+	}
+	if string(sum) != string(expected) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrDigestMismatch, digest, formatDigest(sum))
+	}
+	return nil
+}
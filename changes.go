@@ -0,0 +1,159 @@
+package pouchdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Change represents a single entry in a changes feed.
+type Change struct {
+	// ID is the document ID.
+	ID string `json:"id"`
+	// Seq is the update sequence of this change. For local databases this is
+	// a number; for CouchDB 2.x it is an opaque string. It may be passed as
+	// ChangesOptions.Since to resume the feed after this change.
+	Seq interface{} `json:"seq"`
+	// Changes lists the leaf revisions affected by this change.
+	Changes []ChangeRev `json:"changes"`
+	// Deleted is true if the document has been deleted.
+	Deleted bool `json:"deleted"`
+	// Doc contains the raw document, if IncludeDocs was set. Use ScanDoc to
+	// decode it.
+	Doc json.RawMessage `json:"doc"`
+}
+
+// ChangeRev is a single revision reference within a Change.
+type ChangeRev struct {
+	Rev string `json:"rev"`
+}
+
+// Revs returns the list of revisions included in the change.
+func (c *Change) Revs() []string {
+	revs := make([]string, len(c.Changes))
+	for i, rev := range c.Changes {
+		revs[i] = rev.Rev
+	}
+	return revs
+}
+
+// ScanDoc unmarshals the document included with the change into doc. It is
+// only meaningful if the feed was requested with IncludeDocs.
+func (c *Change) ScanDoc(doc interface{}) error {
+	if len(c.Doc) == 0 {
+		return errors.New("no document included with change")
+	}
+	return json.Unmarshal(c.Doc, doc)
+}
+
+// ChangesFeed is an iterator over a PouchDB changes feed. Its use mirrors that
+// of sql.Rows:
+//
+//    feed, err := db.Changes(ctx, pouchdb.ChangesOptions{Live: true})
+//    if err != nil {
+//        return err
+//    }
+//    defer feed.Close()
+//    for feed.Next() {
+//        change := feed.Change()
+//        ...
+//    }
+//    if err := feed.Err(); err != nil {
+//        return err
+//    }
+type ChangesFeed struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	feed   *js.Object
+	q      *eventQueue
+	change *Change
+	err    error
+	closed bool
+}
+
+// Changes returns a feed of changes made to documents in the database. Unless
+// opts.Live is set, the feed ends once all changes up to the present have
+// been delivered. Cancelling ctx, or calling Close, cancels the underlying
+// PouchDB changes request.
+//
+// See: https://pouchdb.com/api.html#changes
+func (db *PouchDB) Changes(ctx context.Context, opts ChangesOptions) (*ChangesFeed, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	q := newEventQueue()
+	feed := db.Call("changes", opts.compile())
+	feed.Call("on", "change", func(c *js.Object) {
+		change := &Change{}
+		if err := ConvertJSObject(c, change); err != nil {
+			q.close(err)
+			return
+		}
+		q.push(change)
+	})
+	feed.Call("on", "complete", func(_ *js.Object) {
+		q.close(nil)
+	})
+	feed.Call("on", "error", func(e *js.Object) {
+		q.close(NewPouchError(&js.Error{Object: e}))
+	})
+	go func() {
+		<-ctx.Done()
+		feed.Call("cancel")
+	}()
+	return &ChangesFeed{
+		ctx:    ctx,
+		cancel: cancel,
+		feed:   feed,
+		q:      q,
+	}, nil
+}
+
+// Next prepares the next change for reading with the Change method. It
+// returns true on success, or false if the feed has ended or an error
+// occurred. Err should be consulted to distinguish between the two cases.
+// In live mode, Next blocks until a new change arrives.
+func (f *ChangesFeed) Next() bool {
+	if f.closed {
+		return false
+	}
+	item, err := f.q.pop(f.ctx)
+	if err != nil {
+		if err == io.EOF {
+			// The feed also completes when cancelled from outside
+			err = f.ctx.Err()
+		}
+		if !f.closed {
+			// Errors caused by Close() being called concurrently are not
+			// errors from the caller's point of view
+			f.err = err
+		}
+		f.change = nil
+		f.Close()
+		return false
+	}
+	f.change = item.(*Change)
+	return true
+}
+
+// Change returns the current change, as prepared by Next.
+func (f *ChangesFeed) Change() *Change {
+	return f.change
+}
+
+// Err returns the error, if any, that was encountered during iteration.
+func (f *ChangesFeed) Err() error {
+	return f.err
+}
+
+// Close stops the feed and cancels the underlying PouchDB request. It is safe
+// to call Close more than once.
+func (f *ChangesFeed) Close() error {
+	f.closed = true
+	f.cancel()
+	return nil
+}
@@ -0,0 +1,92 @@
+package pouchdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestChanges(t *testing.T) {
+	db := newPouch("testdb")
+	for _, id := range []string{"foo", "bar"} {
+		if _, err := db.Put(TestDoc{DocId: id, Value: id}); err != nil {
+			t.Fatalf("Error calling Put(): %s", err)
+		}
+	}
+	feed, err := db.Changes(context.Background(), ChangesOptions{
+		IncludeDocs: true,
+	})
+	if err != nil {
+		t.Fatalf("Error calling Changes(): %s", err)
+	}
+	seen := make(map[string]bool)
+	for feed.Next() {
+		change := feed.Change()
+		if len(change.Revs()) != 1 {
+			t.Errorf("Unexpected revs for %s: %v", change.ID, change.Revs())
+		}
+		var doc TestDoc
+		if err := change.ScanDoc(&doc); err != nil {
+			t.Fatalf("Error scanning doc: %s", err)
+		}
+		if doc.Value != change.ID {
+			t.Errorf("Unexpected doc value '%s' for %s", doc.Value, change.ID)
+		}
+		seen[change.ID] = true
+	}
+	if err := feed.Err(); err != nil {
+		t.Fatalf("Changes feed returned error: %s", err)
+	}
+	if !seen["foo"] || !seen["bar"] {
+		t.Fatalf("Did not see all changes: %v", seen)
+	}
+	db.Destroy(Options{})
+}
+
+func TestChangesLive(t *testing.T) {
+	db := newPouch("testdb")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	feed, err := db.Changes(ctx, ChangesOptions{
+		Live:  true,
+		Since: "now",
+	})
+	if err != nil {
+		t.Fatalf("Error calling Changes(): %s", err)
+	}
+	go func() {
+		if _, err := db.Put(TestDoc{DocId: "live"}); err != nil {
+			t.Errorf("Error calling Put(): %s", err)
+		}
+	}()
+	if !feed.Next() {
+		t.Fatalf("Expected a change, got error: %v", feed.Err())
+	}
+	if id := feed.Change().ID; id != "live" {
+		t.Errorf("Unexpected change for '%s'", id)
+	}
+	feed.Close()
+	if feed.Next() {
+		t.Errorf("Next() returned true after Close()")
+	}
+	if err := feed.Err(); err != nil {
+		t.Errorf("Unexpected error after Close(): %s", err)
+	}
+	db.Destroy(Options{})
+}
+
+func TestChangesCancel(t *testing.T) {
+	db := newPouch("testdb")
+	ctx, cancel := context.WithCancel(context.Background())
+	feed, err := db.Changes(ctx, ChangesOptions{Live: true})
+	if err != nil {
+		t.Fatalf("Error calling Changes(): %s", err)
+	}
+	cancel()
+	for feed.Next() {
+	}
+	if err := feed.Err(); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	db.Destroy(Options{})
+}
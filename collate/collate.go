@@ -0,0 +1,315 @@
+// Package collate implements the collation of view keys used by PouchDB,
+// following pouchdb-collate. Keys sort by type first:
+//
+//    null < false < true < numbers < strings < arrays < objects
+//
+// Numbers sort numerically. Arrays and objects sort element by element, then
+// by length; object members sort by key first, then by value.
+//
+// PouchDB compares strings by their UTF-16 code units, as JavaScript does,
+// and so do Compare, Less and Sort. CouchDB instead collates strings with
+// ICU, which orders them case-insensitively first ("a" < "B" < "b" < "c"),
+// so the order of string keys in results from a CouchDB server may differ.
+// The icu subpackage follows CouchDB's order instead. It is kept separate,
+// as its collation tables add considerably to the size of compiled code.
+//
+// Keys may be any value which can be marshalled to JSON. Values are
+// normalized as PouchDB does: numbers which are not finite become null.
+// Objects compare in the order of their members in the JSON encoding, so Go
+// maps compare by sorted keys, and structs by field order.
+package collate
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"unicode/utf16"
+)
+
+// The collation index of each type of value.
+const (
+	nullIndex = iota + 1
+	boolIndex
+	numberIndex
+	stringIndex
+	arrayIndex
+	objectIndex
+)
+
+// member is a member of an object, which keeps the order of its members.
+type member struct {
+	key   string
+	value interface{}
+}
+
+// object is the normalized form of a JSON object.
+type object []member
+
+// Compare compares two view keys. It returns -1 if a sorts before b, 1 if it
+// sorts after b, and 0 if they are equal.
+func Compare(a, b interface{}) int {
+	return compare(normalize(a), normalize(b), compareStrings)
+}
+
+// Less reports whether key a sorts before key b.
+func Less(a, b interface{}) bool {
+	return Compare(a, b) < 0
+}
+
+// Sort sorts keys in collation order. The sort is stable.
+func Sort(keys []interface{}) {
+	SortWith(keys, compareStrings)
+}
+
+// CompareWith compares two view keys like Compare, but compares strings,
+// including the keys of objects, with cmp, which returns -1, 0 or 1.
+func CompareWith(a, b interface{}, cmp func(a, b string) int) int {
+	return compare(normalize(a), normalize(b), cmp)
+}
+
+// SortWith sorts keys like Sort, but compares strings, including the keys of
+// objects, with cmp, as CompareWith does. The sort is stable.
+func SortWith(keys []interface{}, cmp func(a, b string) int) {
+	normalized := make([]interface{}, len(keys))
+	for i, key := range keys {
+		normalized[i] = normalize(key)
+	}
+	sort.Stable(&sorter{keys: keys, normalized: normalized, compareStrings: cmp})
+}
+
+type sorter struct {
+	keys, normalized []interface{}
+	compareStrings   stringComparer
+}
+
+func (s *sorter) Len() int { return len(s.keys) }
+func (s *sorter) Less(i, j int) bool {
+	return compare(s.normalized[i], s.normalized[j], s.compareStrings) < 0
+}
+func (s *sorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.normalized[i], s.normalized[j] = s.normalized[j], s.normalized[i]
+}
+
+// stringComparer compares two strings, returning -1, 0 or 1.
+type stringComparer func(a, b string) int
+
+// compare compares normalized keys, comparing strings, including object
+// keys, with cmp.
+func compare(a, b interface{}, cmp stringComparer) int {
+	ai, bi := collationIndex(a), collationIndex(b)
+	if ai != bi {
+		return sign(ai - bi)
+	}
+	switch a := a.(type) {
+	case bool:
+		return compareBools(a, b.(bool))
+	case float64:
+		return compareNumbers(a, b.(float64))
+	case string:
+		return cmp(a, b.(string))
+	case []interface{}:
+		return compareArrays(a, b.([]interface{}), cmp)
+	case object:
+		return compareObjects(a, b.(object), cmp)
+	}
+	return 0
+}
+
+func collationIndex(v interface{}) int {
+	switch v.(type) {
+	case bool:
+		return boolIndex
+	case float64:
+		return numberIndex
+	case string:
+		return stringIndex
+	case []interface{}:
+		return arrayIndex
+	case object:
+		return objectIndex
+	}
+	return nullIndex
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	}
+	return 1
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareStrings compares strings by their UTF-16 code units, as
+// JavaScript's < operator does.
+func compareStrings(a, b string) int {
+	if a == b {
+		return 0
+	}
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return sign(int(ua[i]) - int(ub[i]))
+		}
+	}
+	return sign(len(ua) - len(ub))
+}
+
+func compareArrays(a, b []interface{}, cmp stringComparer) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compare(a[i], b[i], cmp); c != 0 {
+			return c
+		}
+	}
+	return sign(len(a) - len(b))
+}
+
+func compareObjects(a, b object, cmp stringComparer) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := cmp(a[i].key, b[i].key); c != 0 {
+			return c
+		}
+		if c := compare(a[i].value, b[i].value, cmp); c != 0 {
+			return c
+		}
+	}
+	return sign(len(a) - len(b))
+}
+
+// normalize converts v to nil, bool, float64, string, []interface{} or
+// object.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, nullKey:
+		return nil
+	case bool:
+		return v
+	case string:
+		return v
+	case float64:
+		return normalizeNumber(v)
+	case float32:
+		return normalizeNumber(float64(v))
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil
+		}
+		return normalizeNumber(f)
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, elem := range v {
+			normalized[i] = normalize(elem)
+		}
+		return normalized
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		normalized := make(object, len(keys))
+		for i, key := range keys {
+			normalized[i] = member{key: key, value: normalize(v[key])}
+		}
+		return normalized
+	case json.RawMessage:
+		return decodeJSON(v)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return decodeJSON(encoded)
+}
+
+// normalizeNumber converts numbers which can't be represented in JSON to
+// null, as PouchDB does.
+func normalizeNumber(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
+
+// decodeJSON decodes JSON into its normalized form, keeping the order of
+// object members. Invalid JSON is treated as null.
+func decodeJSON(data []byte) interface{} {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	v, err := decodeValue(dec)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			elem, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, elem)
+		}
+		_, err := dec.Token()
+		return arr, err
+	case json.Delim('{'):
+		obj := object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, member{key: key.(string), value: value})
+		}
+		_, err := dec.Token()
+		return obj, err
+	}
+	return tok, nil
+}
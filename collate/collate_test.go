@@ -0,0 +1,181 @@
+package collate
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// orderedKeys are in collation order, with no two equal.
+var orderedKeys = []interface{}{
+	nil,
+	false,
+	true,
+	-math.MaxFloat64,
+	-1e100,
+	-300,
+	-2.5,
+	-1,
+	-0.001,
+	0,
+	1e-300,
+	0.5,
+	1,
+	2,
+	10,
+	123.456,
+	1e100,
+	math.MaxFloat64,
+	"",
+	"A",
+	"B",
+	"a",
+	"aa",
+	"b",
+	"é",
+	"｡",
+	[]interface{}{},
+	[]interface{}{nil},
+	[]interface{}{1},
+	[]interface{}{1, "a"},
+	[]interface{}{"a"},
+	[]interface{}{"a", []interface{}{}},
+	[]interface{}{"b"},
+	map[string]interface{}{},
+	map[string]interface{}{"a": 1},
+	map[string]interface{}{"a": 2},
+	map[string]interface{}{"b": 1},
+	map[string]interface{}{"b": 1, "c": 2},
+}
+
+func TestCompare(t *testing.T) {
+	for i, a := range orderedKeys {
+		for j, b := range orderedKeys {
+			expected := sign(i - j)
+			if c := Compare(a, b); c != expected {
+				t.Errorf("Compare(%#v, %#v) = %d, expected %d", a, b, c, expected)
+			}
+		}
+	}
+}
+
+func TestCompareNormalization(t *testing.T) {
+	tests := []struct {
+		a, b interface{}
+	}{
+		{math.NaN(), nil},
+		{math.Inf(1), nil},
+		{int64(3), 3.0},
+		{uint8(3), 3.0},
+		{json.Number("3"), 3.0},
+		{json.RawMessage(`["a",{"b":1}]`), []interface{}{"a", map[string]interface{}{"b": 1}}},
+		{struct {
+			B int `json:"b"`
+			A int `json:"a"`
+		}{1, 2}, json.RawMessage(`{"b":1,"a":2}`)},
+		{[]string{"x", "y"}, []interface{}{"x", "y"}},
+	}
+	for _, test := range tests {
+		if c := Compare(test.a, test.b); c != 0 {
+			t.Errorf("Compare(%#v, %#v) = %d, expected 0", test.a, test.b, c)
+		}
+	}
+	// Object members are compared in order, not by key
+	if !Less(json.RawMessage(`{"a":2,"b":1}`), json.RawMessage(`{"b":1,"a":2}`)) {
+		t.Errorf("Expected member order to be significant")
+	}
+}
+
+func TestSort(t *testing.T) {
+	keys := make([]interface{}, len(orderedKeys))
+	for i := range orderedKeys {
+		keys[i] = orderedKeys[len(orderedKeys)-1-i]
+	}
+	Sort(keys)
+	if !reflect.DeepEqual(keys, orderedKeys) {
+		t.Errorf("Unexpected order: %v", keys)
+	}
+}
+
+func TestToIndexableString(t *testing.T) {
+	tests := []struct {
+		key      interface{}
+		expected string
+	}{
+		{nil, "1\x00"},
+		{true, "21\x00"},
+		{0, "31\x00"},
+		{1, "323241\x00"},
+		{-1, "303249\x00"},
+		{12.5, "323251.25\x00"},
+		{"a\x00b", "4a\x01\x01b\x00"},
+		{[]interface{}{nil, "x"}, "51\x004x\x00\x00"},
+		{map[string]interface{}{"a": false}, "64a\x0020\x00\x00"},
+	}
+	for _, test := range tests {
+		if s := ToIndexableString(test.key); s != test.expected {
+			t.Errorf("ToIndexableString(%#v) = %q, expected %q", test.key, s, test.expected)
+		}
+	}
+	for i := 1; i < len(orderedKeys); i++ {
+		a, b := ToIndexableString(orderedKeys[i-1]), ToIndexableString(orderedKeys[i])
+		if compareStrings(a, b) >= 0 {
+			t.Errorf("Indexable strings out of order: %q >= %q", a, b)
+		}
+	}
+}
+
+func TestParseIndexableString(t *testing.T) {
+	for _, key := range append(orderedKeys, "\x00\x01\x02", 3.14159) {
+		parsed, err := ParseIndexableString(ToIndexableString(key))
+		if err != nil {
+			t.Errorf("Error parsing encoded %#v: %s", key, err)
+			continue
+		}
+		if Compare(parsed, key) != 0 {
+			t.Errorf("%#v did not round-trip, got %#v", key, parsed)
+		}
+	}
+	// Negative numbers may lose their last digits
+	for _, num := range []float64{-1234.5, -1234.5678e-9, -0.3} {
+		parsed, err := ParseIndexableString(ToIndexableString(num))
+		if err != nil {
+			t.Errorf("Error parsing encoded %v: %s", num, err)
+			continue
+		}
+		if f, ok := parsed.(float64); !ok || math.Abs((f-num)/num) > 1e-14 {
+			t.Errorf("%v did not round-trip, got %#v", num, parsed)
+		}
+	}
+	for _, invalid := range []string{"", "7\x00", "1", "4abc", "5", "3abc\x00", "1\x00x"} {
+		if _, err := ParseIndexableString(invalid); err != ErrInvalidIndexableString {
+			t.Errorf("ParseIndexableString(%q): expected ErrInvalidIndexableString, got %v", invalid, err)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	r := PrefixRange("post")
+	for _, key := range []interface{}{[]interface{}{"post"}, []interface{}{"post", 2015}, []interface{}{"post", 2015, "x"}} {
+		if !r.Contains(key) {
+			t.Errorf("Expected %v to be in %v", key, r)
+		}
+	}
+	for _, key := range []interface{}{"post", []interface{}{"page", 2015}, []interface{}{"posts"}} {
+		if r.Contains(key) {
+			t.Errorf("Expected %v not to be in %v", key, r)
+		}
+	}
+	s := StringPrefixRange("ab")
+	if !s.Contains("ab") || !s.Contains("abz") || s.Contains("ac") || s.Contains("a") {
+		t.Errorf("Unexpected string prefix range results")
+	}
+	exclusive := Range{Start: 1, End: 2, ExclusiveEnd: true}
+	if !exclusive.Contains(1) || exclusive.Contains(2) {
+		t.Errorf("Unexpected exclusive range results")
+	}
+	if MinKey == nil || Compare(MinKey, nil) != 0 || !Less([]interface{}{"b"}, MaxKey) {
+		t.Errorf("Unexpected sentinel order")
+	}
+}
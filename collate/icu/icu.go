@@ -0,0 +1,54 @@
+// Package icu compares and sorts view keys in the order used by CouchDB,
+// which collates strings with ICU rather than by their UTF-16 code units, as
+// PouchDB does. Keys are otherwise ordered as by package collate.
+//
+// It depends on golang.org/x/text/collate, whose collation tables add
+// considerably to the size of compiled code, so it is kept apart from
+// package collate.
+package icu
+
+import (
+	"sync"
+
+	textcollate "golang.org/x/text/collate"
+	"golang.org/x/text/language"
+
+	"github.com/flimzy/go-pouchdb/collate"
+)
+
+var (
+	// collator compares strings with the root collation of the Unicode
+	// Collation Algorithm, as CouchDB does with ICU. It is created on first
+	// use, and guarded by mu, as a Collator may not be used concurrently.
+	collator *textcollate.Collator
+	mu       sync.Mutex
+)
+
+// compareStrings compares two strings with collator.
+func compareStrings(a, b string) int {
+	mu.Lock()
+	defer mu.Unlock()
+	if collator == nil {
+		collator = textcollate.New(language.Und)
+	}
+	return collator.CompareString(a, b)
+}
+
+// Compare compares two view keys like collate.Compare, but orders strings,
+// and the keys of objects, as CouchDB does: by the Unicode Collation
+// Algorithm, which compares letters case-insensitively before comparing case
+// and accents, putting lowercase first ("a" < "A" < "aa" < "b").
+func Compare(a, b interface{}) int {
+	return collate.CompareWith(a, b, compareStrings)
+}
+
+// Less reports whether key a sorts before key b in CouchDB's order.
+func Less(a, b interface{}) bool {
+	return Compare(a, b) < 0
+}
+
+// Sort sorts keys in CouchDB's order, as given by Compare. The sort is
+// stable.
+func Sort(keys []interface{}) {
+	collate.SortWith(keys, compareStrings)
+}
@@ -0,0 +1,77 @@
+package icu
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/flimzy/go-pouchdb/collate"
+)
+
+func TestCompare(t *testing.T) {
+	// The order of https://docs.couchdb.org/en/stable/ddocs/views/collation.html
+	ordered := []interface{}{
+		nil,
+		false,
+		true,
+		1,
+		2,
+		3.0,
+		4,
+		"a",
+		"A",
+		"aa",
+		"b",
+		"B",
+		"ba",
+		"bb",
+		[]interface{}{"a"},
+		[]interface{}{"b"},
+		[]interface{}{"b", "c"},
+		[]interface{}{"b", "c", "a"},
+		[]interface{}{"b", "d"},
+		[]interface{}{"b", "d", "e"},
+		map[string]interface{}{"a": 1},
+		map[string]interface{}{"a": 2},
+		map[string]interface{}{"b": 1},
+		map[string]interface{}{"b": 2},
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			if c := Compare(a, b); c != expected {
+				t.Errorf("Compare(%#v, %#v) = %d, expected %d", a, b, c, expected)
+			}
+		}
+	}
+	if !collate.Less("B", "a") || !Less("a", "B") {
+		t.Errorf("Expected code unit order from collate.Less, and ICU order from Less")
+	}
+	keys := []interface{}{"b", "B", "A", "a"}
+	Sort(keys)
+	if expected := []interface{}{"a", "A", "b", "B"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected order: %v", keys)
+	}
+}
+
+func TestConcurrentCompare(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if !Less("a", "B") {
+					t.Errorf("Expected a < B")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,233 @@
+package collate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The encoding of numbers, as in pouchdb-collate.
+const (
+	// minMagnitude is the smallest decimal exponent of a float64.
+	minMagnitude = -324
+	// magnitudeDigits is the number of digits of the encoded exponent.
+	magnitudeDigits = 3
+)
+
+// ToIndexableString encodes a key as a string, such that comparing the
+// encoded strings gives the same order as comparing the keys with Compare.
+// The encoding is the one used by pouchdb-collate's toIndexableString(), so
+// the strings may be stored in, or compared with those of, PouchDB.
+//
+// Like JavaScript, the order is that of the strings' UTF-16 code units. Go's
+// < operator compares UTF-8 bytes instead, which only differs for strings
+// containing characters above U+FFFF, or between U+E000 and U+FFFF.
+func ToIndexableString(key interface{}) string {
+	var b strings.Builder
+	writeIndexable(&b, normalize(key))
+	return b.String()
+}
+
+func writeIndexable(b *strings.Builder, v interface{}) {
+	b.WriteByte(byte('0' + collationIndex(v)))
+	switch v := v.(type) {
+	case bool:
+		if v {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	case float64:
+		b.WriteString(numToIndexableString(v))
+	case string:
+		// Make sure the string contains no \x00, with order-preserving
+		// replacements
+		b.WriteString(indexableStringReplacer.Replace(v))
+	case []interface{}:
+		for _, elem := range v {
+			writeIndexable(b, elem)
+		}
+	case object:
+		for _, m := range v {
+			writeIndexable(b, m.key)
+			writeIndexable(b, m.value)
+		}
+	}
+	b.WriteByte(0)
+}
+
+var (
+	indexableStringReplacer = strings.NewReplacer("\x00", "\x01\x01", "\x01", "\x01\x02", "\x02", "\x02\x02")
+	parsedStringReplacer    = strings.NewReplacer("\x01\x01", "\x00", "\x01\x02", "\x01", "\x02\x02", "\x02")
+)
+
+// numToIndexableString encodes a number as a sign (0 for negative, 1 for
+// zero, 2 for positive), followed by its decimal exponent and mantissa, both
+// adjusted for negative numbers so that they sort correctly.
+func numToIndexableString(num float64) string {
+	if num == 0 {
+		return "1"
+	}
+	neg := num < 0
+	parts := strings.Split(strconv.FormatFloat(num, 'e', -1, 64), "e")
+	magnitude, _ := strconv.Atoi(parts[1])
+	factor, _ := strconv.ParseFloat(parts[0], 64)
+	factor = math.Abs(factor)
+	result := "2"
+	if neg {
+		result = "0"
+		magnitude = -magnitude
+		factor = 10 - factor
+	}
+	result += fmt.Sprintf("%0*d", magnitudeDigits, magnitude-minMagnitude)
+	factorStr := strconv.FormatFloat(factor, 'f', 20, 64)
+	// Strip trailing zeros, and the decimal point if nothing is left after it
+	factorStr = strings.TrimRight(factorStr, "0")
+	factorStr = strings.TrimSuffix(factorStr, ".")
+	return result + factorStr
+}
+
+// ErrInvalidIndexableString is returned by ParseIndexableString when its
+// input is not a valid encoding.
+var ErrInvalidIndexableString = errors.New("collate: invalid indexable string")
+
+// ParseIndexableString decodes a key encoded by ToIndexableString. Numbers
+// are decoded as float64, and objects as map[string]interface{}. As with
+// pouchdb-collate, the last digits of negative numbers may not survive the
+// round trip.
+func ParseIndexableString(s string) (interface{}, error) {
+	p := &parser{s: s}
+	v, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(s) {
+		return nil, ErrInvalidIndexableString
+	}
+	return v, nil
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) next() (byte, error) {
+	if p.pos >= len(p.s) {
+		return 0, ErrInvalidIndexableString
+	}
+	c := p.s[p.pos]
+	p.pos++
+	return c, nil
+}
+
+// until returns the input up to the next \x00, and consumes the \x00.
+func (p *parser) until() (string, error) {
+	end := strings.IndexByte(p.s[p.pos:], 0)
+	if end < 0 {
+		return "", ErrInvalidIndexableString
+	}
+	str := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	return str, nil
+}
+
+// atEnd consumes the \x00 which ends an array or object, if it is next.
+func (p *parser) atEnd() bool {
+	if p.pos < len(p.s) && p.s[p.pos] == 0 {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parse() (interface{}, error) {
+	index, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch index - '0' {
+	case nullIndex:
+		str, err := p.until()
+		if err != nil || str != "" {
+			return nil, ErrInvalidIndexableString
+		}
+		return nil, nil
+	case boolIndex:
+		str, err := p.until()
+		if err != nil || (str != "0" && str != "1") {
+			return nil, ErrInvalidIndexableString
+		}
+		return str == "1", nil
+	case numberIndex:
+		str, err := p.until()
+		if err != nil {
+			return nil, err
+		}
+		return parseNumber(str)
+	case stringIndex:
+		str, err := p.until()
+		if err != nil {
+			return nil, err
+		}
+		return parsedStringReplacer.Replace(str), nil
+	case arrayIndex:
+		arr := []interface{}{}
+		for !p.atEnd() {
+			elem, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, elem)
+		}
+		return arr, nil
+	case objectIndex:
+		obj := map[string]interface{}{}
+		for !p.atEnd() {
+			key, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, ErrInvalidIndexableString
+			}
+			if obj[k], err = p.parse(); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return nil, ErrInvalidIndexableString
+}
+
+// parseNumber reverses numToIndexableString.
+func parseNumber(str string) (float64, error) {
+	if str == "1" {
+		return 0, nil
+	}
+	if len(str) < 1+magnitudeDigits+1 || (str[0] != '0' && str[0] != '2') {
+		return 0, ErrInvalidIndexableString
+	}
+	neg := str[0] == '0'
+	magnitude, err := strconv.Atoi(str[1 : 1+magnitudeDigits])
+	if err != nil {
+		return 0, ErrInvalidIndexableString
+	}
+	magnitude += minMagnitude
+	factor, err := strconv.ParseFloat(str[1+magnitudeDigits:], 64)
+	if err != nil {
+		return 0, ErrInvalidIndexableString
+	}
+	if neg {
+		magnitude = -magnitude
+		factor -= 10
+	}
+	num, err := strconv.ParseFloat(strconv.FormatFloat(factor, 'f', -1, 64)+"e"+strconv.Itoa(magnitude), 64)
+	if err != nil {
+		return 0, ErrInvalidIndexableString
+	}
+	return num, nil
+}
@@ -0,0 +1,54 @@
+package collate
+
+type nullKey struct{}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (nullKey) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// MinKey is the lowest possible key, null. It is not nil, as nil keys are
+// treated as unset in query options; pouchdb.Null is the same value.
+var MinKey interface{} = nullKey{}
+
+// MaxKey is the empty object, {}, which sorts after all keys other than
+// non-empty objects. It is the conventional upper bound of key ranges.
+var MaxKey interface{} = map[string]interface{}{}
+
+// HighString is appended to a string prefix to form the upper bound of the
+// range of strings starting with it. It sorts after all characters in common
+// use.
+const HighString = "\ufff0"
+
+// Range is a range of keys, like the StartKey, EndKey and ExclusiveEnd view
+// query options.
+type Range struct {
+	Start        interface{}
+	End          interface{}
+	ExclusiveEnd bool
+}
+
+// PrefixRange returns the range of array keys which start with the elements
+// of prefix, from [prefix...] to [prefix..., {}]. For instance,
+// PrefixRange("post") matches ["post"], ["post", 2015] and
+// ["post", 2015, "x"], but not ["page", 2015].
+func PrefixRange(prefix ...interface{}) Range {
+	start := append([]interface{}{}, prefix...)
+	end := append(append([]interface{}{}, prefix...), MaxKey)
+	return Range{Start: start, End: end}
+}
+
+// StringPrefixRange returns the range of string keys starting with prefix.
+func StringPrefixRange(prefix string) Range {
+	return Range{Start: prefix, End: prefix + HighString}
+}
+
+// Contains reports whether key falls within the range.
+func (r Range) Contains(key interface{}) bool {
+	k := normalize(key)
+	if compare(normalize(r.Start), k, compareStrings) > 0 {
+		return false
+	}
+	c := compare(k, normalize(r.End), compareStrings)
+	return c < 0 || (c == 0 && !r.ExclusiveEnd)
+}
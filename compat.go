@@ -0,0 +1,138 @@
+package pouchdb
+
+// The methods in this file convert the deprecated Options type into the
+// per-operation option types, so that existing code can be migrated one call
+// at a time:
+//
+//    db.Get(id, &doc, opts.GetOptions())
+//
+// Fields of Options which don't apply to the target operation are dropped.
+
+// DBOptions returns the fields of o which apply to NewWithOpts().
+func (o Options) DBOptions() DBOptions {
+	return DBOptions{
+		AutoCompaction: o.AutoCompaction,
+		Adapter:        o.Adapter,
+		DB:             o.DB,
+		RevsLimit:      o.RevsLimit,
+		Ajax:           o.Ajax,
+		Storage:        o.Storage,
+		Size:           o.Size,
+	}
+}
+
+// GetOptions returns the fields of o which apply to Get().
+func (o Options) GetOptions() GetOptions {
+	return GetOptions{
+		Rev:         o.Rev,
+		Revs:        o.Revs,
+		RevsInfo:    o.RevsInfo,
+		OpenRevs:    o.OpenRevs,
+		AllOpenRevs: o.AllOpenRevs,
+		Conflicts:   o.Conflicts,
+		Attachments: o.Attachments,
+	}
+}
+
+// BulkGetOptions returns the fields of o which apply to BulkGet().
+func (o Options) BulkGetOptions() BulkGetOptions {
+	return BulkGetOptions{
+		Revs:        o.Revs,
+		Attachments: o.Attachments,
+	}
+}
+
+// AllDocsOptions returns the fields of o which apply to AllDocs().
+func (o Options) AllDocsOptions() AllDocsOptions {
+	return AllDocsOptions{
+		Conflicts:    o.Conflicts,
+		Attachments:  o.Attachments,
+		IncludeDocs:  o.IncludeDocs,
+		StartKey:     o.StartKey,
+		EndKey:       o.EndKey,
+		ExclusiveEnd: o.ExclusiveEnd,
+		Limit:        o.Limit,
+		Skip:         o.Skip,
+		Descending:   o.Descending,
+		Key:          o.Key,
+		Keys:         o.Keys,
+	}
+}
+
+// QueryOptions returns the fields of o which apply to Query() and
+// QueryFunc(). MapFuncName and MapFunc have no equivalent; pass the view name
+// to Query() instead.
+func (o Options) QueryOptions() QueryOptions {
+	opts := QueryOptions{
+		Conflicts:      o.Conflicts,
+		Attachments:    o.Attachments,
+		IncludeDocs:    o.IncludeDocs,
+		ExclusiveEnd:   o.ExclusiveEnd,
+		Limit:          o.Limit,
+		Skip:           o.Skip,
+		Descending:     o.Descending,
+		ReduceFuncName: o.ReduceFuncName,
+		ReduceFunc:     o.ReduceFunc,
+		Group:          o.Group,
+		GroupLevel:     o.GroupLevel,
+		Stale:          o.Stale,
+	}
+	if o.StartKey != "" {
+		opts.StartKey = o.StartKey
+	}
+	if o.EndKey != "" {
+		opts.EndKey = o.EndKey
+	}
+	if o.Key != "" {
+		opts.Key = o.Key
+	}
+	for _, key := range o.Keys {
+		opts.Keys = append(opts.Keys, key)
+	}
+	return opts
+}
+
+// ReplicateOptions returns the fields of o which apply to Replicate(),
+// ReplicateLive() and Sync().
+func (o Options) ReplicateOptions() ReplicateOptions {
+	opts := ReplicateOptions{
+		Live:            o.Live,
+		Retry:           o.Retry,
+		Filter:          o.Filter,
+		DocIDs:          o.DocIDs,
+		QueryParams:     o.QueryParams,
+		View:            o.View,
+		Heartbeat:       o.Heartbeat,
+		Timeout:         o.Timeout,
+		BatchSize:       o.BatchSize,
+		BatchesLimit:    o.BatchesLimit,
+		BackOffFunction: o.BackOffFunction,
+	}
+	if o.Since > 0 {
+		opts.Since = o.Since
+	}
+	return opts
+}
+
+// ChangesOptions returns the fields of o which apply to Changes().
+func (o Options) ChangesOptions() ChangesOptions {
+	opts := ChangesOptions{
+		Live:        o.Live,
+		IncludeDocs: o.IncludeDocs,
+		Conflicts:   o.Conflicts,
+		Attachments: o.Attachments,
+		Descending:  o.Descending,
+		Limit:       o.Limit,
+		BatchSize:   o.BatchSize,
+		Filter:      o.Filter,
+		DocIDs:      o.DocIDs,
+		QueryParams: o.QueryParams,
+		View:        o.View,
+		Timeout:     o.Timeout,
+		Heartbeat:   o.Heartbeat,
+	}
+	if o.Since > 0 {
+		opts.Since = o.Since
+	}
+	return opts
+}
@@ -0,0 +1,87 @@
+package pouchdb
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCancelledContext(t *testing.T) {
+	db := newPouch("testdb")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var doc TestDoc
+	if err := db.GetContext(ctx, "foo", &doc, GetOptions{}); err != context.Canceled {
+		t.Errorf("GetContext(): expected context.Canceled, got %v", err)
+	}
+	if _, err := db.InfoContext(ctx); err != context.Canceled {
+		t.Errorf("InfoContext(): expected context.Canceled, got %v", err)
+	}
+	if _, err := ReplicateContext(ctx, db, newPouch("db2"), ReplicateOptions{}); err != context.Canceled {
+		t.Errorf("ReplicateContext(): expected context.Canceled, got %v", err)
+	}
+	db.Destroy(Options{})
+}
+
+func TestCancelledContextWrites(t *testing.T) {
+	db := newPouch("cancelleddb")
+	defer db.Destroy(Options{})
+	rev, err := db.Put(TestDoc{DocId: "existing", Value: "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.PutContext(ctx, TestDoc{DocId: "new"}); err != context.Canceled {
+		t.Errorf("PutContext(): expected context.Canceled, got %v", err)
+	}
+	if _, err := db.BulkDocsContext(ctx, []TestDoc{{DocId: "bulk"}}, BulkDocsOptions{}); err != context.Canceled {
+		t.Errorf("BulkDocsContext(): expected context.Canceled, got %v", err)
+	}
+	if _, err := db.RemoveContext(ctx, TestDoc{DocId: "existing", DocRev: rev}, Options{}); err != context.Canceled {
+		t.Errorf("RemoveContext(): expected context.Canceled, got %v", err)
+	}
+	att := &Attachment{Name: "foo.txt", Type: "text/plain", Body: ioutil.NopCloser(strings.NewReader("data"))}
+	if _, err := db.PutAttachmentContext(ctx, "existing", att, rev); err != context.Canceled {
+		t.Errorf("PutAttachmentContext(): expected context.Canceled, got %v", err)
+	}
+	if err := db.DestroyContext(ctx, Options{}); err != context.Canceled {
+		t.Errorf("DestroyContext(): expected context.Canceled, got %v", err)
+	}
+
+	// None of the writes happened
+	for _, id := range []string{"new", "bulk"} {
+		var doc TestDoc
+		if err := db.Get(id, &doc, GetOptions{}); !IsNotExist(err) {
+			t.Errorf("Expected %s not to exist, got %v", id, err)
+		}
+	}
+	var doc TestDoc
+	if err := db.Get("existing", &doc, GetOptions{}); err != nil {
+		t.Errorf("Expected the existing doc to remain: %s", err)
+	} else if doc.DocRev != rev {
+		t.Errorf("Expected rev %s, got %s", rev, doc.DocRev)
+	}
+}
+
+func TestReplicateLiveContext(t *testing.T) {
+	newPouch("db1").Destroy(Options{})
+	newPouch("db2").Destroy(Options{})
+	db1 := newPouch("db1")
+	db2 := newPouch("db2")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repl := ReplicateLiveContext(ctx, db1, db2, ReplicateOptions{})
+	for repl.Next() {
+		if repl.Event().Type == ReplicationPaused {
+			cancel()
+		}
+	}
+	if _, err := repl.Wait(); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	db1.Destroy(Options{})
+	db2.Destroy(Options{})
+}
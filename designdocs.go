@@ -0,0 +1,216 @@
+package pouchdb
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// DesignDocPrefix is the prefix of every design document's ID.
+const DesignDocPrefix = "_design/"
+
+// DesignDoc represents a design document, which holds the JavaScript source
+// of views, filters and validation functions.
+//
+// See http://docs.couchdb.org/en/latest/api/ddoc/common.html
+type DesignDoc struct {
+	// ID is the document ID. The "_design/" prefix may be omitted when
+	// writing.
+	ID  string `json:"_id"`
+	Rev string `json:"_rev,omitempty"`
+
+	// Language of the functions. Defaults to "javascript".
+	Language string `json:"language,omitempty"`
+
+	// Views, keyed by name.
+	Views map[string]*View `json:"views,omitempty"`
+
+	// Filter functions for the changes feed and replication, keyed by name.
+	Filters map[string]string `json:"filters,omitempty"`
+
+	// ValidateDocUpdate is a function which may reject writes, by throwing
+	// an error.
+	ValidateDocUpdate string `json:"validate_doc_update,omitempty"`
+
+	// Options for the views of this design document.
+	Options *DesignDocOptions `json:"options,omitempty"`
+
+	// Extra holds the fields of the design document not covered above, such
+	// as updates, shows or lists, keyed by name, so that they are kept when
+	// the design document is written back. Special fields, whose names begin
+	// with an underscore, are not included.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// queryLanguage is the language of the design documents in which
+// pouchdb-find stores Mango indexes. Their views are not JavaScript, and
+// can't be represented by DesignDoc.
+const queryLanguage = "query"
+
+// designDoc has the fields of DesignDoc, but not its methods, so it can be
+// marshalled without recursion.
+type designDoc DesignDoc
+
+// MarshalJSON satisfies the json.Marshaler interface. The Extra fields are
+// merged into the design document.
+func (d DesignDoc) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(designDoc(d))
+	if err != nil || len(d.Extra) == 0 {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range d.Extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+func (d *DesignDoc) UnmarshalJSON(data []byte) error {
+	var doc designDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for name := range fields {
+		switch name {
+		case "language", "views", "filters", "validate_doc_update", "options":
+			delete(fields, name)
+		default:
+			if strings.HasPrefix(name, "_") {
+				delete(fields, name)
+			}
+		}
+	}
+	doc.Extra = nil
+	if len(fields) > 0 {
+		doc.Extra = fields
+	}
+	*d = DesignDoc(doc)
+	return nil
+}
+
+// View is the definition of a view in a design document.
+type View struct {
+	// Map is the source of the map function.
+	Map string `json:"map"`
+
+	// Reduce is the source of the reduce function, or the name of a built-in
+	// reduce function, such as "_count", "_sum" or "_stats".
+	Reduce string `json:"reduce,omitempty"`
+}
+
+// DesignDocOptions are the options of a design document.
+type DesignDocOptions struct {
+	// Include the sequence number of each document in the map function's
+	// doc argument, as _local_seq.
+	LocalSeq bool `json:"local_seq,omitempty"`
+
+	// Include design documents in the views.
+	IncludeDesign bool `json:"include_design,omitempty"`
+}
+
+// Name returns the design document's ID without the "_design/" prefix, as
+// used in view names such as "name/view".
+func (d *DesignDoc) Name() string {
+	return strings.TrimPrefix(d.ID, DesignDocPrefix)
+}
+
+// designDocID adds the "_design/" prefix to name, unless already present.
+func designDocID(name string) string {
+	if strings.HasPrefix(name, DesignDocPrefix) {
+		return name
+	}
+	return DesignDocPrefix + name
+}
+
+// PutDesignDoc creates or updates a design document. To update an existing
+// design document, ddoc.Rev must be set to its current revision.
+func (db *PouchDB) PutDesignDoc(ddoc *DesignDoc) (newrev string, err error) {
+	return db.PutDesignDocContext(context.Background(), ddoc)
+}
+
+// PutDesignDocContext is like PutDesignDoc, but accepts a context.
+func (db *PouchDB) PutDesignDocContext(ctx context.Context, ddoc *DesignDoc) (newrev string, err error) {
+	doc := *ddoc
+	doc.ID = designDocID(ddoc.ID)
+	return db.PutContext(ctx, &doc)
+}
+
+// GetDesignDoc retrieves a design document. The "_design/" prefix of name
+// may be omitted. Design documents holding Mango indexes can't be retrieved
+// this way.
+func (db *PouchDB) GetDesignDoc(name string) (*DesignDoc, error) {
+	return db.GetDesignDocContext(context.Background(), name)
+}
+
+// GetDesignDocContext is like GetDesignDoc, but accepts a context.
+func (db *PouchDB) GetDesignDocContext(ctx context.Context, name string) (*DesignDoc, error) {
+	ddoc := &DesignDoc{}
+	if err := db.GetContext(ctx, designDocID(name), ddoc, GetOptions{}); err != nil {
+		return nil, err
+	}
+	return ddoc, nil
+}
+
+// ListDesignDocs retrieves all design documents in the database, sorted by
+// ID. The design documents which hold Mango indexes, whose language is
+// "query", are skipped; use the find plugin's GetIndexes() to list them.
+func (db *PouchDB) ListDesignDocs() ([]*DesignDoc, error) {
+	return db.ListDesignDocsContext(context.Background())
+}
+
+// ListDesignDocsContext is like ListDesignDocs, but accepts a context.
+func (db *PouchDB) ListDesignDocsContext(ctx context.Context) ([]*DesignDoc, error) {
+	result, err := db.AllDocsContext(ctx, AllDocsOptions{
+		StartKey:    DesignDocPrefix,
+		EndKey:      DesignDocPrefix + "\ufff0",
+		IncludeDocs: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ddocs := make([]*DesignDoc, 0, result.Rows.Len())
+	for result.Rows.Next() {
+		row := result.Rows.Row()
+		// Checked before decoding, as the map functions of Mango indexes
+		// are objects.
+		var lang struct {
+			Language string `json:"language"`
+		}
+		if err := row.ScanDoc(&lang); err != nil {
+			return nil, err
+		}
+		if lang.Language == queryLanguage {
+			continue
+		}
+		ddoc := &DesignDoc{}
+		if err := row.ScanDoc(ddoc); err != nil {
+			return nil, err
+		}
+		ddocs = append(ddocs, ddoc)
+	}
+	return ddocs, nil
+}
+
+// DeleteDesignDoc deletes a design document. The "_design/" prefix of name
+// may be omitted.
+func (db *PouchDB) DeleteDesignDoc(name, rev string) (newrev string, err error) {
+	return db.DeleteDesignDocContext(context.Background(), name, rev)
+}
+
+// DeleteDesignDocContext is like DeleteDesignDoc, but accepts a context.
+func (db *PouchDB) DeleteDesignDocContext(ctx context.Context, name, rev string) (newrev string, err error) {
+	return db.RemoveContext(ctx, map[string]string{
+		"_id":  designDocID(name),
+		"_rev": rev,
+	}, Options{})
+}
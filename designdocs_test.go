@@ -0,0 +1,151 @@
+package pouchdb
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDesignDocs(t *testing.T) {
+	db := newPouch("testdb")
+	defer db.Destroy(Options{})
+	if _, err := db.Put(TestDoc{DocId: "doc1", Value: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	ddoc := &DesignDoc{
+		ID: "foo",
+		Views: map[string]*View{
+			"by_foo": {
+				Map:    "function(doc) { if (doc.foo) { emit(doc.foo); } }",
+				Reduce: "_count",
+			},
+		},
+		Filters: map[string]string{
+			"foos": "function(doc) { return !!doc.foo; }",
+		},
+		Options: &DesignDocOptions{LocalSeq: true},
+	}
+	rev, err := db.PutDesignDoc(ddoc)
+	if err != nil {
+		t.Fatalf("Error putting design doc: %s", err)
+	}
+
+	fetched, err := db.GetDesignDoc("foo")
+	if err != nil {
+		t.Fatalf("Error getting design doc: %s", err)
+	}
+	expected := *ddoc
+	expected.ID = "_design/foo"
+	expected.Rev = rev
+	if !reflect.DeepEqual(&expected, fetched) {
+		t.Errorf("Design doc did not round-trip.\nExpected: %+v\n  Actual: %+v", &expected, fetched)
+	}
+	if name := fetched.Name(); name != "foo" {
+		t.Errorf("Expected name foo, got %s", name)
+	}
+
+	result, err := db.Query(fetched.Name()+"/by_foo", QueryOptions{})
+	if err != nil {
+		t.Fatalf("Error querying design doc view: %s", err)
+	}
+	if !result.Rows.Next() {
+		t.Fatal("Expected a reduce row")
+	}
+	var count int
+	if err := result.Rows.Row().ScanValue(&count); err != nil || count != 1 {
+		t.Errorf("Expected count 1, got %d (%v)", count, err)
+	}
+
+	if _, err := db.PutDesignDoc(&DesignDoc{ID: "_design/bar"}); err != nil {
+		t.Fatalf("Error putting design doc: %s", err)
+	}
+	// A Mango index, as created by pouchdb-find, whose map is an object
+	mango := map[string]interface{}{
+		"_id":      "_design/idx-name",
+		"language": "query",
+		"views": map[string]interface{}{
+			"idx-name": map[string]interface{}{
+				"map":     map[string]interface{}{"fields": map[string]string{"name": "asc"}},
+				"reduce":  "_count",
+				"options": map[string]interface{}{"def": map[string]interface{}{"fields": []string{"name"}}},
+			},
+		},
+	}
+	if _, err := db.Put(mango); err != nil {
+		t.Fatalf("Error putting Mango index: %s", err)
+	}
+	ddocs, err := db.ListDesignDocs()
+	if err != nil {
+		t.Fatalf("Error listing design docs: %s", err)
+	}
+	var ids []string
+	for _, d := range ddocs {
+		ids = append(ids, d.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"_design/bar", "_design/foo"}) {
+		t.Errorf("Unexpected design docs: %v", ids)
+	}
+
+	if _, err := db.DeleteDesignDoc("foo", rev); err != nil {
+		t.Fatalf("Error deleting design doc: %s", err)
+	}
+	if _, err := db.GetDesignDoc("foo"); !IsNotExist(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestDesignDocExtra(t *testing.T) {
+	input := `{"_id":"_design/foo","_rev":"1-abc","_conflicts":["1-def"],"language":"javascript",` +
+		`"views":{"bar":{"map":"function(doc) { emit(doc._id); }"}},` +
+		`"shows":{"baz":"function(doc, req) { return 'baz'; }"},"rewrites":[{"from":"/","to":"_show/baz"}]}`
+	ddoc := &DesignDoc{}
+	if err := json.Unmarshal([]byte(input), ddoc); err != nil {
+		t.Fatal(err)
+	}
+	expected := &DesignDoc{
+		ID:       "_design/foo",
+		Rev:      "1-abc",
+		Language: "javascript",
+		Views: map[string]*View{
+			"bar": {Map: "function(doc) { emit(doc._id); }"},
+		},
+		Extra: map[string]json.RawMessage{
+			"shows":    json.RawMessage(`{"baz":"function(doc, req) { return 'baz'; }"}`),
+			"rewrites": json.RawMessage(`[{"from":"/","to":"_show/baz"}]`),
+		},
+	}
+	if !reflect.DeepEqual(expected, ddoc) {
+		t.Errorf("Unexpected design doc.\nExpected: %+v\n  Actual: %+v", expected, ddoc)
+	}
+
+	output, err := json.Marshal(ddoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(output, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"_id", "_rev", "language", "views", "shows", "rewrites"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("Field %s was not marshalled: %s", name, output)
+		}
+	}
+	if _, ok := fields["_conflicts"]; ok {
+		t.Errorf("Special field _conflicts was kept: %s", output)
+	}
+
+	// Known fields take precedence over Extra
+	ddoc.Extra["language"] = json.RawMessage(`"query"`)
+	output, err = json.Marshal(ddoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip := &DesignDoc{}
+	if err := json.Unmarshal(output, roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	if roundTrip.Language != "javascript" {
+		t.Errorf("Expected language javascript, got %s", roundTrip.Language)
+	}
+}
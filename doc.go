@@ -8,4 +8,14 @@
 //    wrap your calls in goroutines
 //  - They return errors as the last return value (Go style) rather than the
 //    first (JS style)
+//  - They have variants, suffixed with Context, which accept a
+//    context.Context. Once the context is cancelled, these return ctx.Err()
+//    immediately. Replications and changes feeds are cancelled along with
+//    the context; other PouchDB operations cannot be aborted, and may still
+//    complete in the background.
+//  - They decode results into values passed as interface{}, as
+//    encoding/json does, rather than using type parameters. The package is
+//    built with GopherJS for Go 1.13, which has no generics. Reduce rows,
+//    for instance, are decoded with ScanReduceRows into a slice of a struct
+//    type whose Key and Value fields have the desired types.
 package pouchdb
@@ -1,19 +1,47 @@
 package pouchdb
 
 import (
+	"errors"
+
 	"github.com/gopherjs/gopherjs/js"
 )
 
 // PouchError records an error returned by the PouchDB library
 type PouchError struct {
-	e       *js.Error
-	Status  int    `js:"status"`
-	Message string `js:"message"`
-	Name    string `js:"name"`
-	IsError bool   `js:"isError"`
-	Reason  string `js:"reason"`
+	e *js.Error
+	// Status is the HTTP status code associated with the error, e.g. 404
+	// for a missing document. It is 0 for errors which don't originate
+	// from PouchDB itself.
+	Status int
+	// Message is a human-readable description of the error.
+	Message string
+	// Name is the PouchDB error name, such as "not_found" or "conflict", or
+	// the JavaScript error type (e.g. "TypeError") for other errors.
+	Name string
+	// IsError is true for errors generated by PouchDB.
+	IsError bool
+	// Reason gives further detail for some errors.
+	Reason string
+	// DocID is the ID of the document the error relates to, if any.
+	DocID string
 }
 
+// Sentinel errors, which match any PouchError with the same HTTP status when
+// used with errors.Is. For example:
+//
+//    if errors.Is(err, pouchdb.ErrConflict) {
+//        // fetch the latest revision and try again
+//    }
+var (
+	ErrBadRequest         = &PouchError{Status: 400, Name: "bad_request", Message: "Bad Request"}
+	ErrUnauthorized       = &PouchError{Status: 401, Name: "unauthorized", Message: "Name or password is incorrect."}
+	ErrForbidden          = &PouchError{Status: 403, Name: "forbidden", Message: "Forbidden by design doc validate_doc_update function"}
+	ErrNotFound           = &PouchError{Status: 404, Name: "not_found", Message: "missing"}
+	ErrConflict           = &PouchError{Status: 409, Name: "conflict", Message: "Document update conflict"}
+	ErrPreconditionFailed = &PouchError{Status: 412, Name: "precondition_failed", Message: "Database exists"}
+	ErrInternalServer     = &PouchError{Status: 500, Name: "internal_server_error", Message: "Internal Server Error"}
+)
+
 // Error satisfies the error interface for the PouchError type
 func (e *PouchError) Error() string {
 	if e.Reason != "" && e.Reason != "undefined" && e.Reason != e.Message {
@@ -22,10 +50,38 @@ func (e *PouchError) Error() string {
 	return e.Message
 }
 
+// Is reports whether target is a PouchError with the same (non-zero) HTTP
+// status as e. This allows errors to be compared with the sentinel errors,
+// such as ErrNotFound, using errors.Is.
+func (e *PouchError) Is(target error) bool {
+	t, ok := target.(*PouchError)
+	if !ok {
+		return false
+	}
+	return t.Status != 0 && t.Status == e.Status
+}
+
+// Unwrap returns the underlying js.Error object, if any, so that it may be
+// retrieved with errors.As.
+func (e *PouchError) Unwrap() error {
+	if e.e == nil {
+		return nil
+	}
+	return e.e
+}
+
+// asPouchError returns the PouchError in err's chain, or nil.
+func asPouchError(err error) *PouchError {
+	var pe *PouchError
+	if errors.As(err, &pe) {
+		return pe
+	}
+	return nil
+}
+
 // ErrorStatus returns the status of a PouchError, or 0 for other errors
 func ErrorStatus(err error) int {
-	switch pe := err.(type) {
-	case *PouchError:
+	if pe := asPouchError(err); pe != nil {
 		return pe.Status
 	}
 	return 0
@@ -33,8 +89,7 @@ func ErrorStatus(err error) int {
 
 // ErrorMessage returns the message portion of a PouchError, or "" for other errors
 func ErrorMessage(err error) string {
-	switch pe := err.(type) {
-	case *PouchError:
+	if pe := asPouchError(err); pe != nil {
 		return pe.Message
 	}
 	return ""
@@ -42,8 +97,7 @@ func ErrorMessage(err error) string {
 
 // ErrorName returns the name portion of a PouchError, or "" for other errors
 func ErrorName(err error) string {
-	switch pe := err.(type) {
-	case *PouchError:
+	if pe := asPouchError(err); pe != nil {
 		return pe.Name
 	}
 	return ""
@@ -51,8 +105,7 @@ func ErrorName(err error) string {
 
 // ErrorReason returns the reason portion of a PouchError, or "" for other errors
 func ErrorReason(err error) string {
-	switch pe := err.(type) {
-	case *PouchError:
+	if pe := asPouchError(err); pe != nil {
 		return pe.Reason
 	}
 	return ""
@@ -61,39 +114,60 @@ func ErrorReason(err error) string {
 // IsNotExist returns true if the passed error represents a PouchError with
 // a status of 404 (not found)
 func IsNotExist(err error) bool {
-	switch pe := err.(type) {
-	case *PouchError:
-		return pe.Status == 404
-	}
-	return false
+	return errors.Is(err, ErrNotFound)
 }
 
 // IsConflict returns true if the passed error is a PouchError with a status
 // of 409 (conflict)
 func IsConflict(err error) bool {
-	switch pe := err.(type) {
-	case *PouchError:
-		return pe.Status == 409
-	}
-	return false
+	return errors.Is(err, ErrConflict)
 }
 
 // IsPouchError returns true if the passed error is a PouchError, false
 // if it is any other type of error.
 func IsPouchError(err error) bool {
-	switch err.(type) {
-	case *PouchError:
-		return true
-	}
-	return false
+	return asPouchError(err) != nil
 }
 
-// NewPouchError creates a new PouchError from a js.Error object returned from the PouchDB library
+// NewPouchError creates a new PouchError from a js.Error object returned from
+// the PouchDB library, populating its fields from the JavaScript object.
 func NewPouchError(err *js.Error) error {
-	if err == nil {
+	if err == nil || err.Object == nil || err.Object == js.Undefined {
 		return nil
 	}
-	return &PouchError{e: err}
+	o := err.Object
+	pe := &PouchError{
+		e:       err,
+		Message: jsString(o, "message"),
+		Name:    jsString(o, "name"),
+		Reason:  jsString(o, "reason"),
+		DocID:   jsString(o, "docId"),
+	}
+	if status := o.Get("status"); status != js.Undefined && status != nil {
+		pe.Status = status.Int()
+	}
+	if isError := o.Get("error"); isError != js.Undefined && isError != nil {
+		// PouchDB sets error to true; errors relayed from CouchDB carry the
+		// error name here instead.
+		pe.IsError = isError.Bool()
+		if s := isError.String(); pe.Name == "" && s != "true" {
+			pe.Name = s
+		}
+	}
+	if pe.Message == "" {
+		pe.Message = pe.Name
+	}
+	return pe
+}
+
+// jsString returns the named property of o as a string, or "" if it is not
+// set.
+func jsString(o *js.Object, key string) string {
+	v := o.Get(key)
+	if v == js.Undefined || v == nil {
+		return ""
+	}
+	return v.String()
 }
 
 // Underlying returns the underlying js.Error object, as returned from the PouchDB library
@@ -113,9 +187,6 @@ func (w *Warning) Error() string {
 // IsWarning returns true of the error message is a PouchDB warning, otherwise
 // false.
 func IsWarning(err error) bool {
-	switch err.(type) {
-	case *Warning:
-		return true
-	}
-	return false
+	var w *Warning
+	return errors.As(err, &w)
 }
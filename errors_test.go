@@ -0,0 +1,70 @@
+package pouchdb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+func TestNotFoundError(t *testing.T) {
+	db := newPouch("testdb")
+	var doc TestDoc
+	err := db.Get("missing", &doc, GetOptions{})
+	if err == nil {
+		t.Fatal("Expected an error fetching a missing document")
+	}
+	if !errors.Is(err, ErrNotFound) || !IsNotExist(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	if errors.Is(err, ErrConflict) || IsConflict(err) {
+		t.Errorf("Not found error matched ErrConflict")
+	}
+	var pe *PouchError
+	if !errors.As(err, &pe) {
+		t.Fatalf("Expected a *PouchError, got %T", err)
+	}
+	if pe.Status != 404 || pe.Name != "not_found" || pe.Message == "" {
+		t.Errorf("PouchError fields not populated: %#v", pe)
+	}
+	var jsErr *js.Error
+	if !errors.As(err, &jsErr) {
+		t.Errorf("Could not unwrap the underlying *js.Error")
+	}
+	db.Destroy(Options{})
+}
+
+func TestConflictError(t *testing.T) {
+	db := newPouch("testdb")
+	if _, err := db.Put(TestDoc{DocId: "foo"}); err != nil {
+		t.Fatalf("Error calling Put(): %s", err)
+	}
+	_, err := db.Put(TestDoc{DocId: "foo"})
+	if !errors.Is(err, ErrConflict) || !IsConflict(err) {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+	if ErrorStatus(err) != 409 {
+		t.Errorf("Unexpected status: %d", ErrorStatus(err))
+	}
+	db.Destroy(Options{})
+}
+
+func TestNewPouchError(t *testing.T) {
+	if err := NewPouchError(nil); err != nil {
+		t.Errorf("Expected nil, got %v", err)
+	}
+	o := js.Global.Get("Object").New()
+	o.Set("error", "forbidden")
+	o.Set("reason", "Only admins may do that")
+	o.Set("status", 403)
+	err := NewPouchError(&js.Error{Object: o})
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected a forbidden error, got %v", err)
+	}
+	if name := ErrorName(err); name != "forbidden" {
+		t.Errorf("Unexpected name: %s", name)
+	}
+	if reason := ErrorReason(err); reason != "Only admins may do that" {
+		t.Errorf("Unexpected reason: %s", reason)
+	}
+}
@@ -0,0 +1,89 @@
+package pouchdb
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// eventQueue buffers values emitted by JavaScript event listeners until they
+// are consumed by Go code. JavaScript callbacks must never block, so pushing
+// to the queue always returns immediately, regardless of whether anyone is
+// reading.
+type eventQueue struct {
+	mu     sync.Mutex
+	items  []interface{}
+	closed bool
+	err    error
+	ready  chan struct{}
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{
+		ready: make(chan struct{}, 1),
+	}
+}
+
+func (q *eventQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// push appends an item to the queue. Items pushed after the queue has been
+// closed are discarded.
+func (q *eventQueue) push(item interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.items = append(q.items, item)
+	q.notify()
+}
+
+// close marks the end of the stream. Any items already queued may still be
+// read. Only the first call to close has any effect.
+func (q *eventQueue) close(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.err = err
+	q.notify()
+}
+
+// pop returns the next item in the queue, blocking until one is available.
+// Once the queue is closed and drained, it returns the error passed to close,
+// or io.EOF if that was nil. If ctx is cancelled first, ctx.Err() is returned.
+func (q *eventQueue) pop(ctx context.Context) (interface{}, error) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			item := q.items[0]
+			q.items[0] = nil
+			q.items = q.items[1:]
+			q.mu.Unlock()
+			return item, nil
+		}
+		if q.closed {
+			err := q.err
+			q.mu.Unlock()
+			if err == nil {
+				err = io.EOF
+			}
+			// Leave the signal in place for subsequent callers
+			q.notify()
+			return nil, err
+		}
+		q.mu.Unlock()
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,409 @@
+package pouchdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// GoView is a persistent secondary index, whose map function is written in
+// Go. Its rows are stored in a companion database, which is brought up to
+// date incrementally from the source database's changes feed before each
+// query. The sequence up to which the index is current is checkpointed in a
+// _local document of the companion database, so work done is not lost when
+// the program exits.
+//
+// Design documents in the source database are not indexed. If the map
+// function panics, the update fails with an error naming the document, and
+// is retried from the last checkpoint by the next update.
+type GoView struct {
+	name    string
+	source  *PouchDB
+	index   *PouchDB
+	mapFn   MapFunc
+	version string
+	batch   int
+
+	// mu serializes updates of the index.
+	mu sync.Mutex
+
+	// errMu protects updateErr, the error of the last background update.
+	errMu     sync.Mutex
+	updateErr error
+}
+
+// GoViewOptions represents the optional configuration options for
+// NewGoView().
+type GoViewOptions struct {
+	// Index is the companion database which holds the view's rows. It must
+	// not be used for anything else. Defaults to a database named after the
+	// source database and the view, as "<source>-goview-<name>".
+	Index *PouchDB
+
+	// Version identifies the map function. Changing it causes the index to
+	// be rebuilt from scratch, so it should be changed whenever the map
+	// function's output changes.
+	Version string
+
+	// BatchSize is the number of changes indexed at a time, between
+	// checkpoints. Defaults to 100.
+	BatchSize int
+}
+
+const (
+	// goViewDesignDoc is the design document of the companion database.
+	goViewDesignDoc = DesignDocPrefix + "goview"
+	// goViewQuery is the view of the companion database holding the rows.
+	goViewQuery = "goview/rows"
+	// goViewCheckpointID is the _local document holding the checkpoint.
+	goViewCheckpointID = "_local/goview"
+)
+
+// goViewDoc holds the rows emitted for a single source document. It has the
+// same ID as the source document, so the rows returned by the companion
+// database's view carry the right document IDs.
+type goViewDoc struct {
+	ID      string           `json:"_id"`
+	Rev     string           `json:"_rev,omitempty"`
+	Deleted bool             `json:"_deleted,omitempty"`
+	Rows    [][2]interface{} `json:"rows,omitempty"`
+}
+
+type goViewCheckpoint struct {
+	ID      string      `json:"_id"`
+	Rev     string      `json:"_rev,omitempty"`
+	Version string      `json:"version"`
+	Seq     interface{} `json:"seq"`
+}
+
+// NewGoView returns a persistent view of source, named name, whose rows are
+// emitted by fn. The index is only built or updated when the view is first
+// queried, or Update is called.
+func NewGoView(source *PouchDB, name string, fn MapFunc, opts GoViewOptions) *GoView {
+	index := opts.Index
+	if index == nil {
+		index = New(fmt.Sprintf("%s-goview-%s", source.GetJS("name").String(), name))
+	}
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = 100
+	}
+	return &GoView{
+		name:    name,
+		source:  source,
+		index:   index,
+		mapFn:   fn,
+		version: opts.Version,
+		batch:   batch,
+	}
+}
+
+// Name returns the name of the view.
+func (v *GoView) Name() string {
+	return v.name
+}
+
+// Index returns the companion database, which holds the view's rows.
+func (v *GoView) Index() *PouchDB {
+	return v.index
+}
+
+// Update brings the index up to date with the source database.
+func (v *GoView) Update() error {
+	return v.UpdateContext(context.Background())
+}
+
+// UpdateContext is like Update, but accepts a context. If ctx is cancelled,
+// the changes indexed so far are kept.
+func (v *GoView) UpdateContext(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	cp := &goViewCheckpoint{}
+	err := v.index.GetContext(ctx, goViewCheckpointID, cp, GetOptions{})
+	if err != nil && !IsNotExist(err) {
+		return err
+	}
+	if err != nil || cp.Version != v.version {
+		if err := v.reset(ctx); err != nil {
+			return err
+		}
+		cp = &goViewCheckpoint{
+			ID:      goViewCheckpointID,
+			Rev:     cp.Rev,
+			Version: v.version,
+		}
+	}
+	feed, err := v.source.Changes(ctx, ChangesOptions{
+		Since:       cp.Seq,
+		IncludeDocs: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer feed.Close()
+	var batch []*Change
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := v.apply(ctx, batch); err != nil {
+			return err
+		}
+		cp.Seq = batch[len(batch)-1].Seq
+		rev, err := v.index.PutContext(ctx, cp)
+		if err != nil {
+			return err
+		}
+		cp.Rev = rev
+		batch = batch[:0]
+		return nil
+	}
+	for feed.Next() {
+		batch = append(batch, feed.Change())
+		if len(batch) >= v.batch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := feed.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// reset deletes all rows from the index, and makes sure the companion
+// database's design document exists.
+func (v *GoView) reset(ctx context.Context) error {
+	result, err := v.index.AllDocsContext(ctx, AllDocsOptions{})
+	if err != nil {
+		return err
+	}
+	var deleted []goViewDoc
+	for result.Rows.Next() {
+		row := result.Rows.Row()
+		if strings.HasPrefix(row.ID, DesignDocPrefix) {
+			continue
+		}
+		var value struct {
+			Rev string `json:"rev"`
+		}
+		if err := row.ScanValue(&value); err != nil {
+			return err
+		}
+		deleted = append(deleted, goViewDoc{ID: row.ID, Rev: value.Rev, Deleted: true})
+	}
+	if err := v.bulkDocs(ctx, deleted); err != nil {
+		return err
+	}
+	_, err = v.index.PutDesignDocContext(ctx, &DesignDoc{
+		ID: goViewDesignDoc,
+		Views: map[string]*View{
+			"rows": {Map: "function(doc) { if (doc.rows) { doc.rows.forEach(function(row) { emit(row[0], row[1]); }); } }"},
+		},
+	})
+	if err != nil && !IsConflict(err) {
+		return err
+	}
+	return nil
+}
+
+// apply indexes a batch of changes.
+func (v *GoView) apply(ctx context.Context, changes []*Change) error {
+	ids := make([]string, 0, len(changes))
+	latest := make(map[string]*Change, len(changes))
+	for _, change := range changes {
+		if strings.HasPrefix(change.ID, DesignDocPrefix) {
+			continue
+		}
+		if _, ok := latest[change.ID]; !ok {
+			ids = append(ids, change.ID)
+		}
+		latest[change.ID] = change
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	revs, err := v.indexRevs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	docs := make([]goViewDoc, 0, len(ids))
+	for _, id := range ids {
+		var rows [][2]interface{}
+		if change := latest[id]; !change.Deleted {
+			if rows, err = v.mapDoc(change); err != nil {
+				return err
+			}
+		}
+		switch {
+		case len(rows) > 0:
+			docs = append(docs, goViewDoc{ID: id, Rev: revs[id], Rows: rows})
+		case revs[id] != "":
+			docs = append(docs, goViewDoc{ID: id, Rev: revs[id], Deleted: true})
+		}
+	}
+	return v.bulkDocs(ctx, docs)
+}
+
+// indexRevs returns the current revisions of the given documents of the
+// companion database, omitting those which don't exist.
+func (v *GoView) indexRevs(ctx context.Context, ids []string) (map[string]string, error) {
+	result, err := v.index.AllDocsContext(ctx, AllDocsOptions{Keys: ids})
+	if err != nil {
+		return nil, err
+	}
+	revs := make(map[string]string, len(ids))
+	for result.Rows.Next() {
+		row := result.Rows.Row()
+		if row.Error != nil {
+			continue
+		}
+		var value struct {
+			Rev     string `json:"rev"`
+			Deleted bool   `json:"deleted"`
+		}
+		if err := row.ScanValue(&value); err != nil {
+			return nil, err
+		}
+		if !value.Deleted {
+			revs[row.ID] = value.Rev
+		}
+	}
+	return revs, nil
+}
+
+// mapDoc runs the map function on a changed document. Unlike map functions
+// run by PouchDB, a panic in the map function, including one caused by a key
+// or value which can't be marshalled, is returned as an error, so the update
+// stops before the document's batch is checkpointed.
+func (v *GoView) mapDoc(change *Change) (rows [][2]interface{}, err error) {
+	var doc Document
+	if err := change.ScanDoc(&doc); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			rows = nil
+			err = fmt.Errorf("goview %s: doc %s: %v", v.name, change.ID, r)
+		}
+	}()
+	v.mapFn(doc, func(key, value interface{}) {
+		rows = append(rows, [2]interface{}{mustJSON(key), mustJSON(value)})
+	})
+	return rows, nil
+}
+
+func (v *GoView) bulkDocs(ctx context.Context, docs []goViewDoc) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	results, err := v.index.BulkDocsContext(ctx, docs, BulkDocsOptions{})
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if name, ok := result["error"].(string); ok {
+			reason, _ := result["reason"].(string)
+			return errorFromName(name, reason)
+		}
+	}
+	return nil
+}
+
+// Query queries the view, with the same options as PouchDB.Query(). Unless
+// opts.Stale is "ok", the index is updated first; if it is "update_after",
+// the index is updated in the background after the query. An error from a
+// background update is returned by the next query, which is not run.
+//
+// Reduce functions must be written in Go, with opts.Reduce;
+// ReduceFuncName and ReduceFunc are not supported.
+func (v *GoView) Query(opts QueryOptions) (*ViewResult, error) {
+	return v.QueryContext(context.Background(), opts)
+}
+
+// QueryContext is like Query, but accepts a context.
+func (v *GoView) QueryContext(ctx context.Context, opts QueryOptions) (*ViewResult, error) {
+	if opts.ReduceFuncName != "" || opts.ReduceFunc != nil {
+		return nil, errors.New("GoView supports only Go reduce functions")
+	}
+	v.errMu.Lock()
+	err := v.updateErr
+	v.updateErr = nil
+	v.errMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Stale {
+	case "ok":
+	case "update_after":
+		defer func() {
+			go v.updateAfter()
+		}()
+	default:
+		if err := v.UpdateContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+	includeDocs := opts.IncludeDocs && (opts.Reduce == nil || opts.NoReduce)
+	opts.IncludeDocs = false
+	opts.Stale = ""
+	result, err := v.index.QueryContext(ctx, goViewQuery, opts)
+	if err != nil {
+		return nil, err
+	}
+	if includeDocs {
+		if err := v.includeDocs(ctx, result, opts); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// updateAfter updates the index in the background, keeping any error for the
+// next query.
+func (v *GoView) updateAfter() {
+	if err := v.Update(); err != nil {
+		v.errMu.Lock()
+		v.updateErr = err
+		v.errMu.Unlock()
+	}
+}
+
+// includeDocs fills in the Doc of each row from the source database. Rows
+// whose document has been deleted or is missing from the source database,
+// as when the index is stale, get an Error and no Doc.
+func (v *GoView) includeDocs(ctx context.Context, result *ViewResult, opts QueryOptions) error {
+	rows := result.Rows.rows
+	if len(rows) == 0 {
+		return nil
+	}
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	docs, err := v.source.AllDocsContext(ctx, AllDocsOptions{
+		Keys:        ids,
+		IncludeDocs: true,
+		Conflicts:   opts.Conflicts,
+		Attachments: opts.Attachments,
+	})
+	if err != nil {
+		return err
+	}
+	for i := 0; docs.Rows.Next(); i++ {
+		row := docs.Rows.Row()
+		switch {
+		case row.Error != nil:
+			rows[i].Error = row.Error
+		case len(row.Doc) == 0 || string(row.Doc) == "null":
+			// Deleted since the index was last updated
+			rows[i].Error = errorFromName(ErrNotFound.Name, "deleted")
+		default:
+			rows[i].Doc = row.Doc
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package pouchdb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func goViewIDs(t *testing.T, view *GoView, opts QueryOptions) []string {
+	result, err := view.Query(opts)
+	if err != nil {
+		t.Fatalf("Error querying GoView: %s", err)
+	}
+	var ids []string
+	for result.Rows.Next() {
+		ids = append(ids, result.Rows.Row().ID)
+	}
+	return ids
+}
+
+func TestGoView(t *testing.T) {
+	db := newPouch("goviewdb")
+	defer db.Destroy(Options{})
+	index := newPouch("goviewdb-index")
+	defer index.Destroy(Options{})
+	putTestPosts(t, db)
+
+	byType := func(doc Document, emit func(key, value interface{})) {
+		if doc["type"] == "post" {
+			emit([]interface{}{doc["type"], doc["year"]}, doc["title"])
+		}
+	}
+	view := NewGoView(db, "by_type", byType, GoViewOptions{
+		Index:     index,
+		Version:   "1",
+		BatchSize: 2,
+	})
+	ids := goViewIDs(t, view, QueryOptions{
+		StartKey: []interface{}{"post"},
+		EndKey:   []interface{}{"post", HighKey},
+	})
+	if !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Errorf("Unexpected rows: %v", ids)
+	}
+
+	// Changes are picked up incrementally
+	var doc map[string]interface{}
+	if err := db.Get("c", &doc, GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	doc["type"] = "post"
+	if _, err := db.Put(doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Get("a", &doc, GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Remove(doc, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	ids = goViewIDs(t, view, QueryOptions{Key: []interface{}{"post", 2015}})
+	if !reflect.DeepEqual(ids, []string{"b", "c"}) {
+		t.Errorf("Unexpected rows after update: %v", ids)
+	}
+
+	// Docs come from the source database
+	result, err := view.Query(QueryOptions{Limit: 1, IncludeDocs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Rows.Next()
+	var post testPost
+	if err := result.Rows.Row().ScanDoc(&post); err != nil {
+		t.Fatalf("Error scanning included doc: %s", err)
+	}
+	if post.Type != "post" || post.Year != 2015 {
+		t.Errorf("Unexpected doc: %+v", post)
+	}
+
+	// Go reduce functions work
+	result, err = view.Query(QueryOptions{Reduce: countReduce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts := scanReduced(t, result); counts["<nil>"] != 2 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+
+	// The checkpoint is stored in the companion database
+	var cp goViewCheckpoint
+	if err := index.Get(goViewCheckpointID, &cp, GetOptions{}); err != nil {
+		t.Fatalf("Error reading checkpoint: %s", err)
+	}
+	if cp.Version != "1" || cp.Seq == nil {
+		t.Errorf("Unexpected checkpoint: %+v", cp)
+	}
+
+	// A new version rebuilds the index
+	byTitle := func(doc Document, emit func(key, value interface{})) {
+		emit(doc["title"], nil)
+	}
+	view = NewGoView(db, "by_type", byTitle, GoViewOptions{
+		Index:   index,
+		Version: "2",
+	})
+	ids = goViewIDs(t, view, QueryOptions{})
+	if !reflect.DeepEqual(ids, []string{"c", "b"}) {
+		t.Errorf("Unexpected rows after rebuild: %v", ids)
+	}
+
+	// Rows of documents deleted since the last update have an error
+	if err := db.Get("b", &doc, GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Remove(doc, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	result, err = view.Query(QueryOptions{Stale: "ok", Key: "Second", IncludeDocs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Rows.Next() {
+		t.Fatal("Expected a row for b")
+	}
+	if row := result.Rows.Row(); row.ID != "b" || !IsNotExist(row.Error) || row.Doc != nil {
+		t.Errorf("Expected a not found row for b, got %+v", row)
+	}
+}
+
+func TestGoViewMapPanic(t *testing.T) {
+	db := newPouch("goviewpanicdb")
+	defer db.Destroy(Options{})
+	index := newPouch("goviewpanicdb-index")
+	defer index.Destroy(Options{})
+	putTestPosts(t, db)
+
+	byTitle := func(doc Document, emit func(key, value interface{})) {
+		if doc["_id"] == "b" {
+			panic("bad doc")
+		}
+		emit(doc["title"], nil)
+	}
+	view := NewGoView(db, "by_title", byTitle, GoViewOptions{Index: index})
+	err := view.Update()
+	if err == nil || !strings.Contains(err.Error(), "goview by_title: doc b: bad doc") {
+		t.Fatalf("Expected a map function error, got %v", err)
+	}
+	var cp goViewCheckpoint
+	if err := index.Get(goViewCheckpointID, &cp, GetOptions{}); !IsNotExist(err) {
+		t.Errorf("Expected no checkpoint after a failed update, got %+v, %v", cp, err)
+	}
+
+	// A background update's error is returned by the next query, once.
+	view.updateAfter()
+	if _, err := view.Query(QueryOptions{Stale: "ok"}); err == nil || !strings.Contains(err.Error(), "bad doc") {
+		t.Errorf("Expected the background update's error, got %v", err)
+	}
+	if _, err := view.Query(QueryOptions{Stale: "ok"}); err != nil {
+		t.Errorf("Unexpected error after reporting the update error: %s", err)
+	}
+}
@@ -0,0 +1,217 @@
+package pouchdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Document is a document as passed to a MapFunc.
+type Document map[string]interface{}
+
+// ID returns the document's _id.
+func (d Document) ID() string {
+	id, _ := d["_id"].(string)
+	return id
+}
+
+// Rev returns the document's _rev.
+func (d Document) Rev() string {
+	rev, _ := d["_rev"].(string)
+	return rev
+}
+
+// Scan unmarshals the document into dest, honouring its struct tags.
+func (d Document) Scan(dest interface{}) error {
+	return ConvertJSONObject(d, dest)
+}
+
+// MapFunc is a map function written in Go, for use with QueryFunc(). It is
+// called once for each document, and may call emit any number of times to
+// add rows to the view. Keys and values are converted to JSON as if by
+// json.Marshal, so struct tags apply.
+//
+// The function is called synchronously from JavaScript, so it must not
+// block, for instance on channels or on other database calls.
+//
+// As with JavaScript map functions, a panic is reported by PouchDB as an
+// error in the map function, and the document is left out of the view. So
+// is a key or value which can't be marshalled.
+type MapFunc func(doc Document, emit func(key, value interface{}))
+
+// mapFuncSeq numbers the JavaScript wrappers of map functions. PouchDB names
+// the database of a temporary view after its source, so each wrapper must be
+// unique to keep concurrent queries apart.
+var mapFuncSeq uint64
+
+// jsFunc wraps fn in a JavaScript map function.
+func (fn MapFunc) jsFunc() *js.Object {
+	mapFunc := func(doc, emit *js.Object) {
+		goDoc, _ := doc.Interface().(map[string]interface{})
+		fn(Document(goDoc), func(key, value interface{}) {
+			emit.Invoke(mustJSON(key), mustJSON(value))
+		})
+	}
+	// PouchDB only passes emit as an argument to functions which declare
+	// both of their arguments.
+	src := fmt.Sprintf("/* go map function %d */ return function(doc, emit) { fn(doc, emit); };",
+		atomic.AddUint64(&mapFuncSeq, 1))
+	return js.Global.Get("Function").New("fn", src).Invoke(mapFunc)
+}
+
+// mustJSON converts v to the plain value it would have as JSON, panicking
+// if it can't be marshalled.
+func mustJSON(v interface{}) interface{} {
+	var converted interface{}
+	if err := ConvertJSONObject(v, &converted); err != nil {
+		panic(err)
+	}
+	return converted
+}
+
+// ReduceFunc is a reduce function written in Go, for use with the Reduce
+// query option.
+//
+// When rereduce is false, keys holds the key and document ID of each row, and
+// values the matching values. When rereduce is true, keys is nil, and values
+// holds the results of earlier calls to the function. Values are decoded as
+// by json.Unmarshal into an interface{}, and the result is converted to JSON
+// as by json.Marshal. A returned error fails the query.
+//
+// Like a MapFunc, the function must not block.
+type ReduceFunc func(keys [][2]interface{}, values []interface{}, rereduce bool) (interface{}, error)
+
+// reduceFuncsGlobal names the JavaScript global object which holds the Go
+// reduce functions of running queries. PouchDB may re-evaluate the source of
+// a reduce function, so the wrapper must find its Go function by name,
+// rather than through a closure.
+const reduceFuncsGlobal = "_goPouchDBReduceFuncs"
+
+var reduceFuncSeq uint64
+
+// jsReducer bridges a ReduceFunc into PouchDB for the duration of a query.
+type jsReducer struct {
+	fn  ReduceFunc
+	key string
+	// err is the first error returned by fn.
+	err error
+}
+
+// newJSReducer registers fn as a reduce function. The caller must call
+// release once PouchDB has finished the query, not merely once the caller
+// has stopped waiting for it.
+func newJSReducer(fn ReduceFunc) *jsReducer {
+	r := &jsReducer{
+		fn:  fn,
+		key: strconv.FormatUint(atomic.AddUint64(&reduceFuncSeq, 1), 10),
+	}
+	registry := js.Global.Get(reduceFuncsGlobal)
+	if registry == js.Undefined {
+		registry = js.Global.Get("Object").New()
+		js.Global.Set(reduceFuncsGlobal, registry)
+	}
+	registry.Set(r.key, r.reduce)
+	return r
+}
+
+// jsFunc returns the JavaScript reduce function.
+func (r *jsReducer) jsFunc() *js.Object {
+	src := fmt.Sprintf("return function(keys, values, rereduce) { return %s['%s'](keys, values, rereduce); };",
+		reduceFuncsGlobal, r.key)
+	return js.Global.Get("Function").New(src).Invoke()
+}
+
+func (r *jsReducer) reduce(keys, values *js.Object, rereduce bool) interface{} {
+	var goKeys [][2]interface{}
+	if !rereduce {
+		for _, pair := range keys.Interface().([]interface{}) {
+			kv := pair.([]interface{})
+			goKeys = append(goKeys, [2]interface{}{kv[0], kv[1]})
+		}
+	}
+	goValues, _ := values.Interface().([]interface{})
+	result, err := r.fn(goKeys, goValues, rereduce)
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		panic(err)
+	}
+	return mustJSON(result)
+}
+
+func (r *jsReducer) release() {
+	js.Global.Get(reduceFuncsGlobal).Delete(r.key)
+}
+
+// reduceRows reduces the mapped rows of a view in Go, grouping them as
+// specified by opts. Limit and Skip are applied to the reduced rows.
+func reduceRows(rows *Rows, fn ReduceFunc, opts QueryOptions) (*ViewResult, error) {
+	group := opts.Group || opts.GroupLevel > 0
+	var reduced []*Row
+	var groupKey json.RawMessage
+	var keys [][2]interface{}
+	var values []interface{}
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		result, err := fn(keys, values, false)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		reduced = append(reduced, &Row{Key: groupKey, Value: value})
+		keys, values = nil, nil
+		return nil
+	}
+	for rows.Next() {
+		row := rows.Row()
+		var key, value interface{}
+		if err := row.ScanKey(&key); err != nil {
+			return nil, err
+		}
+		if err := row.ScanValue(&value); err != nil {
+			return nil, err
+		}
+		rowGroup := json.RawMessage("null")
+		if group {
+			groupedKey := key
+			if a, ok := key.([]interface{}); ok && opts.GroupLevel > 0 && len(a) > opts.GroupLevel {
+				groupedKey = a[:opts.GroupLevel]
+			}
+			var err error
+			if rowGroup, err = json.Marshal(groupedKey); err != nil {
+				return nil, err
+			}
+		}
+		if !bytes.Equal(rowGroup, groupKey) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			groupKey = rowGroup
+		}
+		keys = append(keys, [2]interface{}{key, row.ID})
+		values = append(values, value)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if opts.Skip > 0 {
+		if opts.Skip > len(reduced) {
+			opts.Skip = len(reduced)
+		}
+		reduced = reduced[opts.Skip:]
+	}
+	if opts.Limit > 0 && opts.Limit < len(reduced) {
+		reduced = reduced[:opts.Limit]
+	}
+	return &ViewResult{Rows: &Rows{rows: reduced}}, nil
+}
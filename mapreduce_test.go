@@ -0,0 +1,225 @@
+package pouchdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testPost struct {
+	Type string `json:"type"`
+	Year int    `json:"year"`
+}
+
+type testPostValue struct {
+	Title string `json:"title"`
+}
+
+func putTestPosts(t *testing.T, db *PouchDB) {
+	docs := []map[string]interface{}{
+		{"_id": "a", "type": "post", "year": 2014, "title": "First"},
+		{"_id": "b", "type": "post", "year": 2015, "title": "Second"},
+		{"_id": "c", "type": "page", "year": 2015, "title": "About"},
+	}
+	if _, err := db.BulkDocs(docs, BulkDocsOptions{}); err != nil {
+		t.Fatalf("Error from BulkDocs: %s", err)
+	}
+}
+
+func TestQueryFunc(t *testing.T) {
+	db := newPouch("testdb")
+	defer db.Destroy(Options{})
+	putTestPosts(t, db)
+	byType := func(doc Document, emit func(key, value interface{})) {
+		var post testPost
+		if err := doc.Scan(&post); err != nil || post.Type != "post" {
+			return
+		}
+		emit([]interface{}{post.Type, post.Year}, testPostValue{Title: doc["title"].(string)})
+	}
+	result, err := db.QueryFunc(byType, QueryOptions{
+		StartKey: []interface{}{"post", 2015},
+		EndKey:   []interface{}{"post", HighKey},
+	})
+	if err != nil {
+		t.Fatalf("Error from QueryFunc: %s", err)
+	}
+	if n := result.Rows.Len(); n != 1 {
+		t.Fatalf("Unexpected number of rows: %d", n)
+	}
+	result.Rows.Next()
+	row := result.Rows.Row()
+	var key []interface{}
+	if err := row.ScanKey(&key); err != nil {
+		t.Fatalf("Error scanning key: %s", err)
+	}
+	var value testPostValue
+	if err := row.ScanValue(&value); err != nil {
+		t.Fatalf("Error scanning value: %s", err)
+	}
+	if row.ID != "b" || !reflect.DeepEqual(key, []interface{}{"post", float64(2015)}) || value.Title != "Second" {
+		t.Errorf("Unexpected row: %s %v %v", row.ID, key, value)
+	}
+
+	// A second function must not reuse the first one's temporary view.
+	byYear := func(doc Document, emit func(key, value interface{})) {
+		emit(doc["year"], nil)
+	}
+	result, err = db.QueryFunc(byYear, QueryOptions{Key: 2015})
+	if err != nil {
+		t.Fatalf("Error from QueryFunc: %s", err)
+	}
+	if n := result.Rows.Len(); n != 2 {
+		t.Errorf("Unexpected number of rows: %d", n)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc := Document{"_id": "foo", "_rev": "1-abc", "type": "post", "year": float64(2015)}
+	if doc.ID() != "foo" || doc.Rev() != "1-abc" {
+		t.Errorf("Unexpected ID/Rev: %s/%s", doc.ID(), doc.Rev())
+	}
+	var post testPost
+	if err := doc.Scan(&post); err != nil {
+		t.Fatal(err)
+	}
+	if post != (testPost{Type: "post", Year: 2015}) {
+		t.Errorf("Unexpected scan result: %+v", post)
+	}
+}
+
+// countReduce counts rows, summing the counts when rereducing.
+func countReduce(keys [][2]interface{}, values []interface{}, rereduce bool) (interface{}, error) {
+	if !rereduce {
+		return len(values), nil
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v.(float64)
+	}
+	return sum, nil
+}
+
+func scanReduced(t *testing.T, result *ViewResult) map[string]int {
+	counts := make(map[string]int)
+	for result.Rows.Next() {
+		var key interface{}
+		var count int
+		if err := result.Rows.Row().ScanKey(&key); err != nil {
+			t.Fatal(err)
+		}
+		if err := result.Rows.Row().ScanValue(&count); err != nil {
+			t.Fatal(err)
+		}
+		counts[fmt.Sprint(key)] = count
+	}
+	return counts
+}
+
+func TestQueryFuncReduce(t *testing.T) {
+	db := newPouch("testdb")
+	defer db.Destroy(Options{})
+	putTestPosts(t, db)
+	byType := func(doc Document, emit func(key, value interface{})) {
+		emit([]interface{}{doc["type"], doc["year"]}, nil)
+	}
+	result, err := db.QueryFunc(byType, QueryOptions{
+		Reduce:     countReduce,
+		GroupLevel: 1,
+	})
+	if err != nil {
+		t.Fatalf("Error from QueryFunc: %s", err)
+	}
+	expected := map[string]int{"[page]": 1, "[post]": 2}
+	if counts := scanReduced(t, result); !reflect.DeepEqual(expected, counts) {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+
+	failing := func(keys [][2]interface{}, values []interface{}, rereduce bool) (interface{}, error) {
+		return nil, errTestReduce
+	}
+	if _, err := db.QueryFunc(byType, QueryOptions{Reduce: failing}); err != errTestReduce {
+		t.Errorf("Expected the reduce error, got %v", err)
+	}
+}
+
+func TestQueryReduce(t *testing.T) {
+	db := newPouch("testdb")
+	defer db.Destroy(Options{})
+	putTestPosts(t, db)
+	_, err := db.PutDesignDoc(&DesignDoc{
+		ID: "posts",
+		Views: map[string]*View{
+			"by_type": {Map: "function(doc) { emit([doc.type, doc.year]); }"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := db.Query("posts/by_type", QueryOptions{
+		Reduce: countReduce,
+		Group:  true,
+	})
+	if err != nil {
+		t.Fatalf("Error from Query: %s", err)
+	}
+	expected := map[string]int{"[page 2015]": 1, "[post 2014]": 1, "[post 2015]": 1}
+	if counts := scanReduced(t, result); !reflect.DeepEqual(expected, counts) {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+}
+
+var errTestReduce = errors.New("reduce failed")
+
+func TestReduceRows(t *testing.T) {
+	row := func(id, key string) *Row {
+		return &Row{ID: id, Key: json.RawMessage(key), Value: json.RawMessage("1")}
+	}
+	newRows := func() *Rows {
+		return &Rows{rows: []*Row{
+			row("a", `["page",2015]`),
+			row("b", `["post",2014]`),
+			row("c", `["post",2015]`),
+			row("d", `["post",2015]`),
+		}}
+	}
+	var calls [][][2]interface{}
+	recordCount := func(keys [][2]interface{}, values []interface{}, rereduce bool) (interface{}, error) {
+		calls = append(calls, keys)
+		return countReduce(keys, values, rereduce)
+	}
+	tests := []struct {
+		name     string
+		opts     QueryOptions
+		expected []string
+	}{
+		{"no group", QueryOptions{}, []string{"null=4"}},
+		{"group", QueryOptions{Group: true}, []string{`["page",2015]=1`, `["post",2014]=1`, `["post",2015]=2`}},
+		{"group level", QueryOptions{GroupLevel: 1}, []string{`["page"]=1`, `["post"]=3`}},
+		{"skip and limit", QueryOptions{Group: true, Skip: 1, Limit: 1}, []string{`["post",2014]=1`}},
+	}
+	for _, test := range tests {
+		result, err := reduceRows(newRows(), recordCount, test.opts)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		var got []string
+		for result.Rows.Next() {
+			r := result.Rows.Row()
+			got = append(got, string(r.Key)+"="+string(r.Value))
+		}
+		if !reflect.DeepEqual(test.expected, got) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+	if expected := [2]interface{}{[]interface{}{"post", float64(2015)}, "d"}; !reflect.DeepEqual(calls[0][3], expected) {
+		t.Errorf("Unexpected key %v", calls[0][3])
+	}
+	if _, err := reduceRows(newRows(), func([][2]interface{}, []interface{}, bool) (interface{}, error) {
+		return nil, errTestReduce
+	}, QueryOptions{}); err != errTestReduce {
+		t.Errorf("Expected the reduce error, got %v", err)
+	}
+}
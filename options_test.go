@@ -19,3 +19,162 @@ func TestOptions(t *testing.T) {
 		t.Fatalf("Got: %v, Expected: %v", compiled, expected)
 	}
 }
+
+func TestChangesOptions(t *testing.T) {
+	opts := ChangesOptions{}
+	expected := make(map[string]interface{})
+	compiled := opts.compile()
+	if !reflect.DeepEqual(compiled, expected) {
+		t.Fatalf("Got: %v, Expected: %v", compiled, expected)
+	}
+	opts.Live = true
+	opts.Since = "now"
+	opts.DocIDs = []string{"foo"}
+	expected["live"] = true
+	expected["since"] = "now"
+	expected["doc_ids"] = []string{"foo"}
+	compiled = opts.compile()
+	if !reflect.DeepEqual(compiled, expected) {
+		t.Fatalf("Got: %v, Expected: %v", compiled, expected)
+	}
+}
+
+func TestTypedOptions(t *testing.T) {
+	type compiler interface {
+		compile() map[string]interface{}
+	}
+	tests := []struct {
+		name     string
+		opts     compiler
+		expected map[string]interface{}
+	}{
+		{"DBOptions", &DBOptions{}, map[string]interface{}{}},
+		{"DBOptions", &DBOptions{AutoCompaction: true, RevsLimit: 5}, map[string]interface{}{
+			"auto_compaction": true,
+			"revs_limit":      5,
+		}},
+		{"GetOptions", &GetOptions{}, map[string]interface{}{}},
+		{"GetOptions", &GetOptions{Rev: "1-abc", OpenRevs: []string{"1-abc"}, AllOpenRevs: true}, map[string]interface{}{
+			"rev":       "1-abc",
+			"open_revs": "all",
+		}},
+		{"BulkGetOptions", &BulkGetOptions{Revs: true}, map[string]interface{}{
+			"revs": true,
+		}},
+		{"BulkDocsOptions", &BulkDocsOptions{NoNewEdits: true}, map[string]interface{}{
+			"new_edits": false,
+		}},
+		{"AllDocsOptions", &AllDocsOptions{}, map[string]interface{}{}},
+		{"AllDocsOptions", &AllDocsOptions{IncludeDocs: true, StartKey: "a", EndKey: "b", ExclusiveEnd: true}, map[string]interface{}{
+			"include_docs":  true,
+			"startkey":      "a",
+			"endkey":        "b",
+			"inclusive_end": false,
+		}},
+		{"ReplicateOptions", &ReplicateOptions{}, map[string]interface{}{}},
+		{"ReplicateOptions", &ReplicateOptions{Live: true, Retry: true, Since: 5}, map[string]interface{}{
+			"live":  true,
+			"retry": true,
+			"since": 5,
+		}},
+		{"CompactOptions", &CompactOptions{Interval: 100}, map[string]interface{}{
+			"interval": 100,
+		}},
+	}
+	for _, test := range tests {
+		compiled := test.opts.compile()
+		if !reflect.DeepEqual(compiled, test.expected) {
+			t.Errorf("%s: Got: %v, Expected: %v", test.name, compiled, test.expected)
+		}
+	}
+}
+
+func TestOptionsCompat(t *testing.T) {
+	opts := Options{
+		Adapter:     "memory",
+		Rev:         "1-abc",
+		IncludeDocs: true,
+		Limit:       10,
+		Group:       true,
+		Since:       7,
+		Live:        true,
+	}
+	if got := opts.DBOptions(); !reflect.DeepEqual(got, DBOptions{Adapter: "memory"}) {
+		t.Errorf("DBOptions(): Got %v", got)
+	}
+	if got := opts.GetOptions(); !reflect.DeepEqual(got, GetOptions{Rev: "1-abc"}) {
+		t.Errorf("GetOptions(): Got %v", got)
+	}
+	if got := opts.AllDocsOptions(); !reflect.DeepEqual(got, AllDocsOptions{IncludeDocs: true, Limit: 10}) {
+		t.Errorf("AllDocsOptions(): Got %v", got)
+	}
+	if got := opts.QueryOptions(); !reflect.DeepEqual(got, QueryOptions{IncludeDocs: true, Limit: 10, Group: true}) {
+		t.Errorf("QueryOptions(): Got %v", got)
+	}
+	if got := opts.ReplicateOptions(); !reflect.DeepEqual(got, ReplicateOptions{Live: true, Since: int64(7)}) {
+		t.Errorf("ReplicateOptions(): Got %v", got)
+	}
+	if got := opts.ChangesOptions(); !reflect.DeepEqual(got, ChangesOptions{Live: true, IncludeDocs: true, Limit: 10, Since: int64(7)}) {
+		t.Errorf("ChangesOptions(): Got %v", got)
+	}
+}
+
+func TestQueryOptions(t *testing.T) {
+	type compoundKey struct {
+		Type string `json:"type"`
+		Year int    `json:"year"`
+	}
+	tests := []struct {
+		name     string
+		opts     QueryOptions
+		expected map[string]interface{}
+	}{
+		{"empty", QueryOptions{}, map[string]interface{}{}},
+		{"reduce", QueryOptions{ReduceFuncName: "_count", Group: true, GroupLevel: 2}, map[string]interface{}{
+			"reduce":      "_count",
+			"group":       true,
+			"group_level": 2,
+		}},
+		{"no reduce", QueryOptions{ReduceFuncName: "_count", NoReduce: true}, map[string]interface{}{
+			"reduce": false,
+		}},
+		{"string keys", QueryOptions{StartKey: "a", EndKey: "b", ExclusiveEnd: true}, map[string]interface{}{
+			"startkey":      "a",
+			"endkey":        "b",
+			"inclusive_end": false,
+		}},
+		{"compound keys", QueryOptions{
+			StartKey:      []interface{}{"post", 2015},
+			EndKey:        []interface{}{"post", HighKey},
+			StartKeyDocID: "abc",
+			EndKeyDocID:   "xyz",
+		}, map[string]interface{}{
+			"startkey":       []interface{}{"post", float64(2015)},
+			"endkey":         []interface{}{"post", map[string]interface{}{}},
+			"startkey_docid": "abc",
+			"endkey_docid":   "xyz",
+		}},
+		{"null key", QueryOptions{Key: Null}, map[string]interface{}{
+			"key": nil,
+		}},
+		{"numeric keys", QueryOptions{Keys: []interface{}{1, 2.5, false}}, map[string]interface{}{
+			"keys": []interface{}{float64(1), 2.5, false},
+		}},
+		{"struct key", QueryOptions{Key: compoundKey{Type: "post", Year: 2015}}, map[string]interface{}{
+			"key": map[string]interface{}{"type": "post", "year": float64(2015)},
+		}},
+	}
+	for _, test := range tests {
+		compiled, err := test.opts.compile()
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(compiled, test.expected) {
+			t.Errorf("%s: Got: %v, Expected: %v", test.name, compiled, test.expected)
+		}
+	}
+	if _, err := (&QueryOptions{Key: make(chan int)}).compile(); err == nil {
+		t.Errorf("Expected an error for an unencodable key")
+	}
+}
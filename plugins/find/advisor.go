@@ -0,0 +1,265 @@
+package find
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flimzy/go-pouchdb"
+)
+
+// Advisor records the shapes of the queries run with Find, and recommends
+// indexes for those which pouchdb-find could only answer by scanning every
+// document. It is meant for use during development. Enable it with
+// EnableAdvisor.
+type Advisor struct {
+	db *PouchPluginFind
+
+	mu     sync.Mutex
+	shapes map[string]*QueryStats
+}
+
+// QueryShape is the shape of a Find request: the fields it selects and sorts
+// by, regardless of the values they are compared with. Fields within $or,
+// $nor and $not are left out, as no index can serve them.
+type QueryShape struct {
+	// Equal lists the fields matched for equality, sorted.
+	Equal []string
+	// Range lists the fields matched with any other operator, sorted.
+	Range []string
+	// Sort is the requested sort order.
+	Sort []SortField
+}
+
+// key identifies the shape, for grouping queries.
+func (s QueryShape) key() string {
+	sortFields := make([]string, len(s.Sort))
+	for i, f := range s.Sort {
+		sortFields[i] = f.Field
+		if f.Desc {
+			sortFields[i] += " desc"
+		}
+	}
+	return strings.Join(s.Equal, ",") + ";" + strings.Join(s.Range, ",") + ";" + strings.Join(sortFields, ",")
+}
+
+// QueryStats counts the queries of a given shape.
+type QueryStats struct {
+	Shape QueryShape
+	// Count is the number of queries run.
+	Count int
+	// FullScans is the number of queries which used no index.
+	FullScans int
+}
+
+// Recommendation suggests an index for queries which used no index.
+type Recommendation struct {
+	QueryStats
+	// Index is the index which would serve the queries.
+	Index Index
+}
+
+// noIndexWarning is the start of the warning returned by pouchdb-find when
+// no index matches a query.
+const noIndexWarning = "no matching index found"
+
+// EnableAdvisor starts recording the queries run with Find, and returns the
+// Advisor which reports on them. It should be called before any queries are
+// run. Calling it again returns the same Advisor.
+func (db *PouchPluginFind) EnableAdvisor() *Advisor {
+	if db.advisor == nil {
+		db.advisor = &Advisor{
+			db:     db,
+			shapes: make(map[string]*QueryStats),
+		}
+	}
+	return db.advisor
+}
+
+// record adds a query to the advisor's statistics.
+func (a *Advisor) record(req map[string]interface{}, warning string) {
+	// Only the selector and sort are decoded, as raw requests may hold other
+	// fields in forms FindRequest doesn't accept, such as a string use_index.
+	var request struct {
+		Selector Selector    `json:"selector"`
+		Sort     []SortField `json:"sort"`
+	}
+	if err := pouchdb.ConvertJSONObject(req, &request); err != nil {
+		// Not a request we can make sense of; pouchdb-find accepted it anyway.
+		return
+	}
+	shape := shapeOf(request.Selector, request.Sort)
+	key := shape.key()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats, ok := a.shapes[key]
+	if !ok {
+		stats = &QueryStats{Shape: shape}
+		a.shapes[key] = stats
+	}
+	stats.Count++
+	if strings.HasPrefix(warning, noIndexWarning) {
+		stats.FullScans++
+	}
+}
+
+// Stats returns the statistics recorded for each query shape, the most
+// frequent first.
+func (a *Advisor) Stats() []*QueryStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]*QueryStats, 0, len(a.shapes))
+	for _, stats := range a.shapes {
+		copied := *stats
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Shape.key() < list[j].Shape.key()
+	})
+	return list
+}
+
+// Reset discards the recorded statistics.
+func (a *Advisor) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.shapes = make(map[string]*QueryStats)
+}
+
+// Recommendations returns an index for each recorded query shape which fell
+// back to a full scan, the most frequent first. Shapes for which an index on
+// the recommended fields now exists are left out, as are shapes with no
+// indexable fields.
+func (a *Advisor) Recommendations() ([]*Recommendation, error) {
+	existing, err := a.db.GetIndexes()
+	if err != nil {
+		return nil, err
+	}
+	var recs []*Recommendation
+	seen := make(map[string]bool)
+	for _, stats := range a.Stats() {
+		if stats.FullScans == 0 {
+			continue
+		}
+		fields := stats.Shape.indexFields()
+		if len(fields) == 0 {
+			continue
+		}
+		key := strings.Join(fields, ",")
+		if seen[key] || indexExists(existing, fields) {
+			continue
+		}
+		seen[key] = true
+		recs = append(recs, &Recommendation{
+			QueryStats: *stats,
+			Index:      Index{Fields: fields},
+		})
+	}
+	sort.SliceStable(recs, func(i, j int) bool {
+		return recs[i].FullScans > recs[j].FullScans
+	})
+	return recs, nil
+}
+
+// indexFields returns the fields of an index serving the shape: the equality
+// fields first, so the remaining fields are read in order, then the sort
+// fields, then the other fields.
+func (s QueryShape) indexFields() []string {
+	var fields []string
+	seen := make(map[string]bool)
+	add := func(field string) {
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	for _, f := range s.Equal {
+		add(f)
+	}
+	for _, f := range s.Sort {
+		add(f.Field)
+	}
+	for _, f := range s.Range {
+		add(f)
+	}
+	return fields
+}
+
+// indexExists returns true if one of indexes is on exactly the given fields.
+func indexExists(indexes []*IndexDef, fields []string) bool {
+	for _, index := range indexes {
+		indexed := index.Def.Fields
+		if len(indexed) != len(fields) {
+			continue
+		}
+		match := true
+		for i, f := range indexed {
+			if f.Field != fields[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// shapeOf returns the shape of a request with the given selector and sort.
+func shapeOf(sel Selector, sortFields []SortField) QueryShape {
+	equal := make(map[string]bool)
+	other := make(map[string]bool)
+	collectShape(sel, "", equal, other)
+	shape := QueryShape{Sort: sortFields}
+	for field := range equal {
+		shape.Equal = append(shape.Equal, field)
+	}
+	for field := range other {
+		if !equal[field] {
+			shape.Range = append(shape.Range, field)
+		}
+	}
+	sort.Strings(shape.Equal)
+	sort.Strings(shape.Range)
+	return shape
+}
+
+// collectShape sorts the fields of sel into those matched for equality and
+// the others, skipping $or, $nor and $not.
+func collectShape(sel map[string]interface{}, prefix string, equal, other map[string]bool) {
+	for key, value := range sel {
+		if key == "$and" {
+			subs, _ := value.([]interface{})
+			for _, sub := range subs {
+				if s, ok := asSelector(sub); ok {
+					collectShape(s, prefix, equal, other)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+		cond, ok := asSelector(value)
+		switch {
+		case !ok:
+			equal[field] = true
+		case isNested(cond):
+			collectShape(cond, field, equal, other)
+		default:
+			if _, ok := cond["$eq"]; ok {
+				equal[field] = true
+			} else {
+				other[field] = true
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// +build js
+
+package find_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flimzy/go-pouchdb"
+	"github.com/flimzy/go-pouchdb/plugins/find"
+)
+
+func TestAdvisor(t *testing.T) {
+	mainDB := pouchdb.NewWithOpts("advisordb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	mainDB.Destroy(pouchdb.Options{}) // to ensure a clean slate
+	mainDB = pouchdb.NewWithOpts("advisordb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	defer mainDB.Destroy(pouchdb.Options{})
+	db := find.New(mainDB)
+	advisor := db.EnableAdvisor()
+	if db.EnableAdvisor() != advisor {
+		t.Errorf("Expected EnableAdvisor to return the same Advisor")
+	}
+	if err := db.CreateIndex(find.Index{Fields: []string{"name"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(req interface{}) {
+		var docs []map[string]interface{}
+		if err := db.Find(req, &docs); err != nil && !pouchdb.IsWarning(err) {
+			t.Fatalf("Error from Find: %s", err)
+		}
+	}
+	for year := 2014; year < 2016; year++ {
+		run(find.FindRequest{
+			Selector: find.And(find.Eq("type", "post"), find.Gt("year", year)),
+		})
+	}
+	run(map[string]interface{}{
+		"selector": map[string]interface{}{"name": "Bob"},
+	})
+
+	stats := advisor.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 query shapes, got %d", len(stats))
+	}
+	expected := find.QueryShape{Equal: []string{"type"}, Range: []string{"year"}}
+	if !reflect.DeepEqual(stats[0].Shape, expected) || stats[0].Count != 2 || stats[0].FullScans != 2 {
+		t.Errorf("Unexpected stats: %+v", stats[0])
+	}
+	if stats[1].FullScans != 0 {
+		t.Errorf("Expected the name query to use an index: %+v", stats[1])
+	}
+
+	recs, err := advisor.Recommendations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || !reflect.DeepEqual(recs[0].Index.Fields, []string{"type", "year"}) {
+		t.Fatalf("Unexpected recommendations: %+v", recs)
+	}
+
+	// Once the index exists, it is no longer recommended
+	if err := db.CreateIndex(recs[0].Index); err != nil {
+		t.Fatal(err)
+	}
+	recs, err = advisor.Recommendations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 0 {
+		t.Errorf("Unexpected recommendations: %+v", recs)
+	}
+
+	advisor.Reset()
+	if len(advisor.Stats()) != 0 {
+		t.Errorf("Expected no stats after Reset")
+	}
+
+	// A query iterated over several pages is recorded once
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		if _, err := mainDB.Put(map[string]interface{}{"_id": name, "name": name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	iter, err := db.FindIter(find.FindRequest{Selector: find.Gt("name", "A")}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for iter.Next() {
+		count++
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 documents, got %d", count)
+	}
+	if stats := advisor.Stats(); len(stats) != 1 || stats[0].Count != 1 {
+		t.Errorf("Expected a single recorded query, got %+v", stats)
+	}
+}
@@ -0,0 +1,252 @@
+package find
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/gopherjs/jsbuiltin"
+
+	"github.com/flimzy/go-pouchdb"
+)
+
+// ErrExplainNotSupported is returned by Explain when the loaded version of
+// pouchdb-find has no explain() method.
+var ErrExplainNotSupported = errors.New("explain is not supported by this version of pouchdb-find")
+
+// QueryPlan describes how a Find request would be executed, as returned by
+// Explain.
+type QueryPlan struct {
+	// Index is the index which would be used. The default index, which
+	// scans all documents by ID, has the name "_all_docs" and the type
+	// "special".
+	Index *IndexDef `json:"index"`
+	// Selector is the request's selector, as normalized by pouchdb-find.
+	Selector Selector `json:"selector"`
+	// Range is the range of index keys which would be read.
+	Range IndexRange `json:"range"`
+	// Fields are the fields to be returned, or nil for all fields.
+	Fields []string `json:"fields"`
+	Limit  int      `json:"limit"`
+	Skip   int      `json:"skip"`
+	// InMemoryFields lists the selector fields which the index cannot
+	// serve, and which must be matched against each document read.
+	InMemoryFields []string `json:"-"`
+	// InMemorySort is true if the results must be sorted after being read,
+	// because the requested sort order does not follow the index.
+	InMemorySort bool `json:"-"`
+}
+
+// IndexRange is a range of index keys.
+type IndexRange struct {
+	StartKey interface{} `json:"start_key"`
+	EndKey   interface{} `json:"end_key"`
+}
+
+// InMemoryFilter returns true if documents read from the index must be
+// filtered further, to match the selector.
+func (p *QueryPlan) InMemoryFilter() bool {
+	return len(p.InMemoryFields) > 0
+}
+
+// FullScan returns true if the plan reads every document in the database,
+// because no index serves the selector.
+func (p *QueryPlan) FullScan() bool {
+	return p.Index != nil && p.Index.Type == IndexTypeSpecial &&
+		p.Range.StartKey == nil && p.Range.EndKey == nil
+}
+
+// Explain returns the plan pouchdb-find would use to execute the request,
+// without executing it.
+//
+// See https://pouchdb.com/api.html#explain_index
+func (db *PouchPluginFind) Explain(request FindRequest) (*QueryPlan, error) {
+	return db.ExplainContext(context.Background(), request)
+}
+
+// ExplainContext is like Explain, but accepts a context.
+func (db *PouchPluginFind) ExplainContext(ctx context.Context, request FindRequest) (*QueryPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if jsbuiltin.TypeOf(db.GetJS("explain")) != "function" {
+		return nil, ErrExplainNotSupported
+	}
+	var req map[string]interface{}
+	if err := pouchdb.ConvertJSONObject(request, &req); err != nil {
+		return nil, err
+	}
+	rw := pouchdb.NewResultWaiterContext(ctx)
+	db.Call("explain", req, rw.Done)
+	result, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	plan := &QueryPlan{}
+	if err := pouchdb.ConvertJSObject(result, plan); err != nil {
+		return nil, err
+	}
+	if plan.Selector == nil {
+		// Round-trip through JSON, so nested values have the same types as
+		// when they come from pouchdb-find.
+		if err := pouchdb.ConvertJSONObject(request.Selector, &plan.Selector); err != nil {
+			return nil, err
+		}
+	}
+	var fields []SortField
+	if plan.Index != nil {
+		fields = plan.Index.Def.Fields
+	}
+	plan.InMemoryFields = inMemoryFields(plan.Selector, fields)
+	plan.InMemorySort = !sortFollowsIndex(request.Sort, fields)
+	return plan, nil
+}
+
+// rangeOperators are the operators which an index can serve, by reading a
+// range of keys.
+var rangeOperators = map[string]bool{
+	"$eq":  true,
+	"$gt":  true,
+	"$gte": true,
+	"$lt":  true,
+	"$lte": true,
+}
+
+// inMemoryFields returns the sorted names of the fields of sel which an index
+// on the given fields cannot serve. As in pouchdb-find, the index serves a
+// leading run of indexed fields matched for equality, followed by at most one
+// field matched with a range. An indexed field which the selector doesn't
+// match, or matches with another operator, ends the run; it and all later
+// indexed fields are matched in memory, as are fields which aren't indexed.
+func inMemoryFields(sel Selector, indexed []SortField) []string {
+	conds := make(map[string]map[string]bool)
+	unserved := make(map[string]bool)
+	collectFields(sel, "", true, conds, unserved)
+	for _, f := range indexed {
+		ops := conds[f.Field]
+		if len(ops) == 0 || !rangeOnly(ops) {
+			break
+		}
+		delete(conds, f.Field)
+		if len(ops) > 1 || !ops["$eq"] {
+			// A range ends the run
+			break
+		}
+	}
+	for field := range conds {
+		unserved[field] = true
+	}
+	if len(unserved) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(unserved))
+	for name := range unserved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rangeOnly returns true if all of ops are range operators.
+func rangeOnly(ops map[string]bool) bool {
+	for op := range ops {
+		if !rangeOperators[op] {
+			return false
+		}
+	}
+	return true
+}
+
+// collectFields adds the operators with which each field of sel is matched to
+// conds, treating implicit equality as $eq. Fields nested within $or, $nor and
+// $not can never be served, as the index only reads a single range, so they
+// are added to unserved instead.
+func collectFields(sel map[string]interface{}, prefix string, servable bool, conds map[string]map[string]bool, unserved map[string]bool) {
+	for key, value := range sel {
+		switch key {
+		case "$and", "$or", "$nor":
+			subs, _ := value.([]interface{})
+			for _, sub := range subs {
+				if s, ok := asSelector(sub); ok {
+					collectFields(s, prefix, servable && key == "$and", conds, unserved)
+				}
+			}
+			continue
+		case "$not":
+			if s, ok := asSelector(value); ok {
+				collectFields(s, prefix, false, conds, unserved)
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+		cond, ok := asSelector(value)
+		if ok && isNested(cond) {
+			collectFields(cond, field, servable, conds, unserved)
+			continue
+		}
+		if !servable {
+			unserved[field] = true
+			continue
+		}
+		ops, seen := conds[field]
+		if !seen {
+			ops = make(map[string]bool)
+			conds[field] = ops
+		}
+		if !ok {
+			ops["$eq"] = true
+			continue
+		}
+		for op := range cond {
+			ops[op] = true
+		}
+	}
+}
+
+// asSelector returns v as a selector, if it is a JSON object.
+func asSelector(v interface{}) (map[string]interface{}, bool) {
+	switch s := v.(type) {
+	case Selector:
+		return s, true
+	case map[string]interface{}:
+		return s, true
+	}
+	return nil, false
+}
+
+// isNested returns true if cond holds sub-fields, rather than operators.
+func isNested(cond map[string]interface{}) bool {
+	for key := range cond {
+		if !strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// sortFollowsIndex returns true if results read from an index on the given
+// fields are already in the requested order: the sort fields must be a
+// prefix of the indexed fields, all in the same direction, which may be the
+// reverse of the index's.
+func sortFollowsIndex(sortFields, indexed []SortField) bool {
+	if len(sortFields) == 0 {
+		return true
+	}
+	if len(sortFields) > len(indexed) {
+		return false
+	}
+	reverse := sortFields[0].Desc != indexed[0].Desc
+	for i, f := range sortFields {
+		if f.Field != indexed[i].Field || (f.Desc != indexed[i].Desc) != reverse {
+			return false
+		}
+	}
+	return true
+}
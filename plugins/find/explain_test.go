@@ -0,0 +1,113 @@
+// +build js
+
+package find_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flimzy/go-pouchdb"
+	"github.com/flimzy/go-pouchdb/plugins/find"
+)
+
+func TestExplain(t *testing.T) {
+	mainDB := pouchdb.NewWithOpts("explaindb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	mainDB.Destroy(pouchdb.Options{}) // to ensure a clean slate
+	mainDB = pouchdb.NewWithOpts("explaindb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	defer mainDB.Destroy(pouchdb.Options{})
+	db := find.New(mainDB)
+
+	// Without a matching index, all documents are scanned
+	plan, err := db.Explain(find.FindRequest{
+		Selector: find.Eq("size", 1),
+		Sort:     []find.SortField{find.Asc("size")},
+	})
+	if err == find.ErrExplainNotSupported {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("Error from Explain: %s", err)
+	}
+	if plan.Index.Name != "_all_docs" || !plan.FullScan() {
+		t.Errorf("Expected a full scan, got %+v", plan)
+	}
+	if !reflect.DeepEqual(plan.InMemoryFields, []string{"size"}) {
+		t.Errorf("Unexpected in-memory fields: %v", plan.InMemoryFields)
+	}
+	if !plan.InMemorySort {
+		t.Errorf("Expected an in-memory sort")
+	}
+
+	if err := db.CreateIndex(find.Index{Fields: []string{"size"}, Name: "by-size", Ddoc: "by-size"}); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = db.Explain(find.FindRequest{
+		Selector: find.And(find.Gt("size", 1), find.Eq("name", "Bob")),
+		Fields:   []string{"_id"},
+		Sort:     []find.SortField{find.Desc("size")},
+		Limit:    10,
+	})
+	if err != nil {
+		t.Fatalf("Error from Explain: %s", err)
+	}
+	if plan.Index.Name != "by-size" || plan.FullScan() {
+		t.Errorf("Expected the by-size index, got %+v", plan.Index)
+	}
+	if plan.Range.StartKey == nil {
+		t.Errorf("Expected a start key")
+	}
+	if !reflect.DeepEqual(plan.InMemoryFields, []string{"name"}) {
+		t.Errorf("Unexpected in-memory fields: %v", plan.InMemoryFields)
+	}
+	if plan.InMemorySort {
+		t.Errorf("Expected the sort to follow the index")
+	}
+	if !reflect.DeepEqual(plan.Fields, []string{"_id"}) || plan.Limit != 10 {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+
+	// A two-field index serves equality on its first field and a range on
+	// its second, but not the reverse. A field missing from the selector
+	// ends the fields served, too.
+	for _, fields := range [][]string{{"name", "size"}, {"name", "age", "size"}} {
+		name := "by-" + strings.Join(fields, "-")
+		if err := db.CreateIndex(find.Index{Fields: fields, Name: name, Ddoc: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		index    string
+		selector find.Selector
+		expected []string
+	}{
+		{"by-name-size", find.And(find.Eq("name", "Bob"), find.Gt("size", 1)), nil},
+		{"by-name-size", find.And(find.Eq("name", "Bob"), find.Eq("size", 1)), nil},
+		{"by-name-size", find.And(find.Gt("name", "Bob"), find.Eq("size", 1)), []string{"size"}},
+		{"by-name-size", find.And(find.Gt("name", "Bob"), find.Lt("size", 3)), []string{"size"}},
+		{"by-name-size", find.And(find.Ne("name", "Bob"), find.Eq("size", 1)), []string{"name", "size"}},
+		{"by-name-size", find.And(find.Eq("name", "Bob"), find.Eq("age", 3)), []string{"age"}},
+		{"by-name-age-size", find.And(find.Eq("name", "Bob"), find.Eq("size", 1)), []string{"size"}},
+		{"by-name-age-size", find.And(find.Eq("name", "Bob"), find.Eq("age", 3), find.Lt("size", 3)), nil},
+	}
+	for _, test := range tests {
+		plan, err := db.Explain(find.FindRequest{
+			Selector: test.selector,
+			UseIndex: []string{test.index, test.index},
+		})
+		if err != nil {
+			t.Fatalf("Error from Explain: %s", err)
+		}
+		if plan.Index.Name != test.index {
+			t.Errorf("Expected the %s index, got %+v", test.index, plan.Index)
+			continue
+		}
+		if !reflect.DeepEqual(plan.InMemoryFields, test.expected) {
+			t.Errorf("%v: expected in-memory fields %v, got %v", test.selector, test.expected, plan.InMemoryFields)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package find
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"github.com/gopherjs/gopherjs/js"
 	"github.com/gopherjs/jsbuiltin"
@@ -12,6 +14,9 @@ import (
 
 type PouchPluginFind struct {
 	*pouchdb.PouchDB
+
+	// advisor, if set, records the queries run with Find.
+	advisor *Advisor
 }
 
 // New loads the pouchdb-find plugin (if not already loaded) and returns
@@ -25,19 +30,97 @@ func New(db *pouchdb.PouchDB) *PouchPluginFind {
 	} else if fnType != "function" {
 		panic("Cannot load pouchdb-find plugin; .createIndex method already exists as a non-function")
 	}
-	return &PouchPluginFind{db}
+	return &PouchPluginFind{PouchDB: db}
 }
 
 // Index defines an index to be created
 type Index struct {
-	// Fields is a list of fields to index
+	// Fields is a list of fields to index, in ascending order.
 	Fields []string `json:"fields"`
+	// Sort lists the fields to index, each in ascending or descending
+	// order, in place of Fields. See Asc() and Desc(). Optional.
+	Sort []SortField `json:"-"`
 	// Name is the name of the index. Optional.
-	Name string `json:"name,omitifempty"`
+	Name string `json:"name,omitempty"`
 	// Design document name (i.e. the part after "_design/"). Optional.
-	Ddoc string `json:"ddoc,omitifempty"`
-	// Type specifies the index type. Only 'json' is supported. Optional.
-	Type string `json:"type,omitifempty"`
+	Ddoc string `json:"ddoc,omitempty"`
+	// Type specifies the index type. Only IndexTypeJSON is supported.
+	// Optional.
+	Type string `json:"type,omitempty"`
+	// PartialFilterSelector limits the index to the documents it matches.
+	// Optional.
+	PartialFilterSelector Selector `json:"partial_filter_selector,omitempty"`
+}
+
+// The index types.
+const (
+	// IndexTypeJSON is the type of the indexes created with CreateIndex.
+	IndexTypeJSON = "json"
+	// IndexTypeSpecial is the type of the built-in _all_docs index.
+	IndexTypeSpecial = "special"
+)
+
+// sortFields returns the indexed fields, from Sort if set, or else from
+// Fields.
+func (i *Index) sortFields() []SortField {
+	if len(i.Sort) > 0 {
+		return i.Sort
+	}
+	fields := make([]SortField, len(i.Fields))
+	for n, name := range i.Fields {
+		fields[n] = Asc(name)
+	}
+	return fields
+}
+
+// MarshalJSON satisfies the json.Marshaler interface. Ascending fields are
+// sent as bare field names, as all versions of pouchdb-find accept them.
+func (i Index) MarshalJSON() ([]byte, error) {
+	if len(i.Fields) > 0 && len(i.Sort) > 0 {
+		return nil, errors.New("index may not have both Fields and Sort")
+	}
+	sortFields := i.sortFields()
+	fields := make([]interface{}, len(sortFields))
+	for n, f := range sortFields {
+		if f.Desc {
+			fields[n] = f
+		} else {
+			fields[n] = f.Field
+		}
+	}
+	// index has no MarshalJSON method, so this doesn't recurse. Its Fields
+	// is shadowed by the outer one.
+	type index Index
+	return json.Marshal(struct {
+		Fields []interface{} `json:"fields"`
+		index
+	}{fields, index(i)})
+}
+
+// Matches returns true if def, as returned by GetIndexes, is an index as
+// described by i. Name, Ddoc and Type are only compared if set in i.
+// Partial filter selectors are compared after normalizing implicit equality
+// and $and, as pouchdb-find does.
+func (i *Index) Matches(def *IndexDef) bool {
+	if i.Name != "" && i.Name != def.Name {
+		return false
+	}
+	if i.Ddoc != "" && pouchdb.DesignDocPrefix+strings.TrimPrefix(i.Ddoc, pouchdb.DesignDocPrefix) != def.Ddoc {
+		return false
+	}
+	if i.Type != "" && i.Type != def.Type {
+		return false
+	}
+	fields := i.sortFields()
+	if len(fields) != len(def.Def.Fields) {
+		return false
+	}
+	for n, field := range fields {
+		if field != def.Def.Fields[n] {
+			return false
+		}
+	}
+	return sameSelector(i.PartialFilterSelector, def.Def.PartialFilterSelector)
 }
 
 type indexWrapper struct {
@@ -49,12 +132,18 @@ type findError struct {
 	exists bool
 }
 
+// Unwrap returns the original error, so that it may be inspected with
+// errors.Is and errors.As.
+func (e *findError) Unwrap() error {
+	return e.error
+}
+
 // IsIndexExists returns true if the error indicates that the index to be created
 // already exists.
 func IsIndexExists(err error) bool {
-	switch e := err.(type) {
-	case *findError:
-		return e.exists
+	var fe *findError
+	if errors.As(err, &fe) {
+		return fe.exists
 	}
 	return false
 }
@@ -63,10 +152,20 @@ func IsIndexExists(err error) bool {
 //
 // See https://github.com/nolanlawson/pouchdb-find#dbcreateindexindex--callback
 func (db *PouchPluginFind) CreateIndex(index Index) error {
+	return db.CreateIndexContext(context.Background(), index)
+}
+
+// CreateIndexContext is like CreateIndex, but accepts a context.
+func (db *PouchPluginFind) CreateIndexContext(ctx context.Context, index Index) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	i := indexWrapper{index}
 	var jsonIndex map[string]interface{}
-	pouchdb.ConvertJSONObject(i, &jsonIndex)
-	rw := pouchdb.NewResultWaiter()
+	if err := pouchdb.ConvertJSONObject(i, &jsonIndex); err != nil {
+		return err
+	}
+	rw := pouchdb.NewResultWaiterContext(ctx)
 	db.Call("createIndex", jsonIndex, rw.Done)
 	result, err := rw.ReadResult()
 	if err != nil {
@@ -83,12 +182,40 @@ func (db *PouchPluginFind) CreateIndex(index Index) error {
 
 // IndexDef describes an index as fetched from the database
 type IndexDef struct {
-	Ddoc string `json:"ddoc"`
-	Name string `json:"name"`
-	Type string `json:"type"`
-	Def  struct {
-		Fields []map[string]string `json:"fields"`
-	} `json:"def"`
+	Ddoc string          `json:"ddoc"`
+	Name string          `json:"name"`
+	Type string          `json:"type"`
+	Def  IndexDefinition `json:"def"`
+}
+
+// IndexDefinition holds the indexed fields and the partial filter of an
+// index fetched from the database.
+type IndexDefinition struct {
+	Fields                []SortField `json:"fields"`
+	PartialFilterSelector Selector    `json:"partial_filter_selector,omitempty"`
+}
+
+// Index returns the definition of the index, which may be passed to
+// CreateIndex to create it again. The fields are given in Fields if they are
+// all ascending, or else in Sort.
+func (d *IndexDef) Index() Index {
+	index := Index{
+		Name:                  d.Name,
+		Ddoc:                  strings.TrimPrefix(d.Ddoc, pouchdb.DesignDocPrefix),
+		Type:                  d.Type,
+		PartialFilterSelector: d.Def.PartialFilterSelector,
+	}
+	for _, f := range d.Def.Fields {
+		if f.Desc {
+			index.Sort = d.Def.Fields
+			return index
+		}
+	}
+	index.Fields = make([]string, len(d.Def.Fields))
+	for n, f := range d.Def.Fields {
+		index.Fields[n] = f.Field
+	}
+	return index
 }
 
 type indexDefsWrapper struct {
@@ -99,7 +226,15 @@ type indexDefsWrapper struct {
 //
 // See https://github.com/nolanlawson/pouchdb-find#dbgetindexescallback
 func (db *PouchPluginFind) GetIndexes() ([]*IndexDef, error) {
-	rw := pouchdb.NewResultWaiter()
+	return db.GetIndexesContext(context.Background())
+}
+
+// GetIndexesContext is like GetIndexes, but accepts a context.
+func (db *PouchPluginFind) GetIndexesContext(ctx context.Context) ([]*IndexDef, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rw := pouchdb.NewResultWaiterContext(ctx)
 	db.Call("getIndexes", rw.Done)
 	result, err := rw.Read()
 	if err != nil {
@@ -117,12 +252,20 @@ func (db *PouchPluginFind) GetIndexes() ([]*IndexDef, error) {
 //
 // See https://github.com/nolanlawson/pouchdb-find#dbdeleteindexindex--callback
 func (db *PouchPluginFind) DeleteIndex(index *IndexDef) error {
+	return db.DeleteIndexContext(context.Background(), index)
+}
+
+// DeleteIndexContext is like DeleteIndex, but accepts a context.
+func (db *PouchPluginFind) DeleteIndexContext(ctx context.Context, index *IndexDef) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	var i map[string]interface{}
 	err := pouchdb.ConvertJSONObject(index, &i)
 	if err != nil {
 		return err
 	}
-	rw := pouchdb.NewResultWaiter()
+	rw := pouchdb.NewResultWaiterContext(ctx)
 	db.Call("deleteIndex", i, rw.Done)
 	_, err = rw.Read()
 	return err
@@ -132,24 +275,30 @@ type findResult struct {
 	Docs    json.RawMessage `json:"docs"`
 	Warning string          `json:"warning,omitempty"`
 	Error   string          `json:"error,omitempty"`
+	// Bookmark is only returned by CouchDB.
+	Bookmark string `json:"bookmark,omitempty"`
 }
 
-// Find performs the requested search query
+// Find performs the requested search query. The request may be a
+// FindRequest, or a raw map[string]interface{}.
 //
 // See https://github.com/nolanlawson/pouchdb-find#dbfindrequest--callback
-func (db *PouchPluginFind) Find(request map[string]interface{}, docs interface{}) error {
-	rw := pouchdb.NewResultWaiter()
-	db.Call("find", request, rw.Done)
-	result, err := rw.Read()
-	if err != nil {
+func (db *PouchPluginFind) Find(request interface{}, docs interface{}) error {
+	return db.FindContext(context.Background(), request, docs)
+}
+
+// FindContext is like Find, but accepts a context.
+func (db *PouchPluginFind) FindContext(ctx context.Context, request interface{}, docs interface{}) error {
+	var req map[string]interface{}
+	if err := pouchdb.ConvertJSONObject(request, &req); err != nil {
 		return err
 	}
-	doc := &findResult{}
-	if err := pouchdb.ConvertJSObject(result, doc); err != nil {
+	doc, err := db.find(ctx, req)
+	if err != nil {
 		return err
 	}
-	if doc.Error != "" {
-		return errors.New(doc.Error)
+	if db.advisor != nil {
+		db.advisor.record(req, doc.Warning)
 	}
 	if err := json.Unmarshal(doc.Docs, docs); err != nil {
 		return err
@@ -159,3 +308,25 @@ func (db *PouchPluginFind) Find(request map[string]interface{}, docs interface{}
 	}
 	return nil
 }
+
+// find runs a request. It is up to the caller to record the request with the
+// advisor, so that FindIter records each query once, not once per page.
+func (db *PouchPluginFind) find(ctx context.Context, req map[string]interface{}) (*findResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rw := pouchdb.NewResultWaiterContext(ctx)
+	db.Call("find", req, rw.Done)
+	result, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	doc := &findResult{}
+	if err := pouchdb.ConvertJSObject(result, doc); err != nil {
+		return nil, err
+	}
+	if doc.Error != "" {
+		return nil, errors.New(doc.Error)
+	}
+	return doc, nil
+}
@@ -3,6 +3,7 @@
 package find_test
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -31,11 +32,11 @@ func init() {
 }
 
 func TestFind(t *testing.T) {
-	mainDB := pouchdb.NewWithOpts("finddb", pouchdb.Options{
+	mainDB := pouchdb.NewWithOpts("finddb", pouchdb.DBOptions{
 		DB: memdown,
 	})
 	mainDB.Destroy(pouchdb.Options{}) // to ensure a clean slate
-	mainDB = pouchdb.NewWithOpts("finddb", pouchdb.Options{
+	mainDB = pouchdb.NewWithOpts("finddb", pouchdb.DBOptions{
 		DB: memdown,
 	})
 
@@ -61,31 +62,34 @@ func TestFind(t *testing.T) {
 	if !find.IsIndexExists(ferr) {
 		t.Fatalf("We were not notified that the index already existed\n")
 	}
+	if !find.IsIndexExists(fmt.Errorf("wrapped: %w", ferr)) {
+		t.Fatalf("IsIndexExists did not recognize a wrapped error\n")
+	}
+
+	// The index's design document is not listed with the others
+	ddocs, err := mainDB.ListDesignDocs()
+	if err != nil {
+		t.Fatalf("Error listing design docs: %s\n", err)
+	}
+	if len(ddocs) != 0 {
+		t.Errorf("Expected no design docs, got %+v\n", ddocs)
+	}
 
 	expected := []*find.IndexDef{
 		&find.IndexDef{
 			Ddoc: "",
 			Name: "_all_docs",
 			Type: "special",
-			Def: struct {
-				Fields []map[string]string "json:\"fields\""
-			}{
-				Fields: []map[string]string{
-					map[string]string{"_id": "asc"},
-				},
+			Def: find.IndexDefinition{
+				Fields: []find.SortField{find.Asc("_id")},
 			},
 		},
 		&find.IndexDef{
-			Ddoc: "_design/idx-1c1850c82e1b5105c94a267ec61322ce",
-			Name: "idx-1c1850c82e1b5105c94a267ec61322ce",
+			Ddoc: "_design/idx-ec10bcf98487b861d3f7a2f076f5f0e9",
+			Name: "idx-ec10bcf98487b861d3f7a2f076f5f0e9",
 			Type: "json",
-			Def: struct {
-				Fields []map[string]string "json:\"fields\""
-			}{
-				Fields: []map[string]string{
-					map[string]string{"name": "asc"},
-					map[string]string{"size": "asc"},
-				},
+			Def: find.IndexDefinition{
+				Fields: []find.SortField{find.Asc("name"), find.Asc("size")},
 			},
 		},
 	}
@@ -155,12 +159,8 @@ func TestFind(t *testing.T) {
 			Ddoc: "",
 			Name: "_all_docs",
 			Type: "special",
-			Def: struct {
-				Fields []map[string]string "json:\"fields\""
-			}{
-				Fields: []map[string]string{
-					map[string]string{"_id": "asc"},
-				},
+			Def: find.IndexDefinition{
+				Fields: []find.SortField{find.Asc("_id")},
 			},
 		},
 	}
@@ -174,6 +174,37 @@ func TestFind(t *testing.T) {
 	}
 }
 
+func TestFindCancelledContext(t *testing.T) {
+	mainDB := pouchdb.NewWithOpts("findctxdb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	defer mainDB.Destroy(pouchdb.Options{})
+	db := find.New(mainDB)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := db.CreateIndexContext(ctx, find.Index{Fields: []string{"name"}}); err != context.Canceled {
+		t.Errorf("CreateIndexContext(): expected context.Canceled, got %v", err)
+	}
+	idxs, err := db.GetIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idxs) != 1 {
+		t.Errorf("Expected only the _all_docs index, got %+v", idxs)
+	}
+	var docs []map[string]interface{}
+	if err := db.FindContext(ctx, find.FindRequest{Selector: find.Eq("name", "Bob")}, &docs); err != context.Canceled {
+		t.Errorf("FindContext(): expected context.Canceled, got %v", err)
+	}
+	if _, err := db.FindIterContext(ctx, find.FindRequest{Selector: find.Eq("name", "Bob")}, 0); err != context.Canceled {
+		t.Errorf("FindIterContext(): expected context.Canceled, got %v", err)
+	}
+	if _, err := find.EnsureSchemaContext(ctx, db, find.Schema{Indexes: []find.Index{{Fields: []string{"name"}}}}); err != context.Canceled {
+		t.Errorf("EnsureSchemaContext(): expected context.Canceled, got %v", err)
+	}
+}
+
 func DumpDiff(expectedObj, actualObj interface{}) {
 	expected := pretty.Sprintf("%# v\n", expectedObj)
 	actual := pretty.Sprintf("%# v\n", actualObj)
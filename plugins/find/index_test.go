@@ -0,0 +1,150 @@
+// +build js
+
+package find_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/flimzy/go-pouchdb"
+	"github.com/flimzy/go-pouchdb/plugins/find"
+)
+
+func TestIndexJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		index    find.Index
+		expected string
+	}{
+		{
+			name:     "fields only",
+			index:    find.Index{Fields: []string{"name", "size"}},
+			expected: `{"fields":["name","size"]}`,
+		},
+		{
+			name: "full",
+			index: find.Index{
+				Sort:                  []find.SortField{find.Asc("name"), find.Desc("size")},
+				Name:                  "by-name",
+				Ddoc:                  "app",
+				Type:                  find.IndexTypeJSON,
+				PartialFilterSelector: find.Eq("type", "post"),
+			},
+			expected: `{"fields":["name",{"size":"desc"}],"name":"by-name","ddoc":"app","type":"json","partial_filter_selector":{"type":{"$eq":"post"}}}`,
+		},
+	}
+	for _, test := range tests {
+		result, err := json.Marshal(test.index)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if string(result) != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, result)
+		}
+	}
+	both := find.Index{Fields: []string{"name"}, Sort: []find.SortField{find.Desc("size")}}
+	if _, err := json.Marshal(both); err == nil {
+		t.Errorf("Expected an error for an index with both Fields and Sort")
+	}
+}
+
+func TestIndexMatches(t *testing.T) {
+	def := &find.IndexDef{
+		Ddoc: "_design/app",
+		Name: "by-name",
+		Type: find.IndexTypeJSON,
+		Def: find.IndexDefinition{
+			Fields:                []find.SortField{find.Asc("name"), find.Desc("size")},
+			PartialFilterSelector: find.Selector{"type": map[string]interface{}{"$eq": "post"}, "year": map[string]interface{}{"$gt": float64(2014)}},
+		},
+	}
+	tests := []struct {
+		name     string
+		index    find.Index
+		expected bool
+	}{
+		{"round trip", def.Index(), true},
+		{"normalized filter", find.Index{
+			Sort:                  []find.SortField{find.Asc("name"), find.Desc("size")},
+			PartialFilterSelector: find.And(find.Selector{"type": "post"}, find.Gt("year", 2014)),
+		}, true},
+		{"direction", find.Index{
+			Fields:                []string{"name", "size"},
+			PartialFilterSelector: def.Def.PartialFilterSelector,
+		}, false},
+		{"filter", find.Index{
+			Sort: []find.SortField{find.Asc("name"), find.Desc("size")},
+		}, false},
+		{"name", find.Index{
+			Sort:                  []find.SortField{find.Asc("name"), find.Desc("size")},
+			Name:                  "other",
+			PartialFilterSelector: def.Def.PartialFilterSelector,
+		}, false},
+	}
+	for _, test := range tests {
+		if result := test.index.Matches(def); result != test.expected {
+			t.Errorf("%s: expected %t, got %t", test.name, test.expected, result)
+		}
+	}
+	if index := def.Index(); index.Ddoc != "app" {
+		t.Errorf("Expected Ddoc without prefix, got %q", index.Ddoc)
+	}
+	def.Def.Fields = []find.SortField{find.Asc("name"), find.Asc("size")}
+	if index := def.Index(); !reflect.DeepEqual(index.Fields, []string{"name", "size"}) || index.Sort != nil {
+		t.Errorf("Expected ascending fields in Fields, got %+v", index)
+	}
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	mainDB := pouchdb.NewWithOpts("indexdb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	mainDB.Destroy(pouchdb.Options{}) // to ensure a clean slate
+	mainDB = pouchdb.NewWithOpts("indexdb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	defer mainDB.Destroy(pouchdb.Options{})
+	db := find.New(mainDB)
+	index := find.Index{
+		Fields:                []string{"year"},
+		Name:                  "posts-by-year",
+		Ddoc:                  "posts",
+		PartialFilterSelector: find.Eq("type", "post"),
+	}
+	if err := db.CreateIndex(index); err != nil {
+		t.Fatal(err)
+	}
+	defs, err := db.GetIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var def *find.IndexDef
+	for _, d := range defs {
+		if d.Name == index.Name {
+			def = d
+		}
+	}
+	if def == nil {
+		t.Fatalf("Index not found: %+v", defs)
+	}
+	if !index.Matches(def) {
+		t.Errorf("Index does not match its definition: %+v", def)
+	}
+
+	// The fetched definition recreates the same index
+	if err := db.DeleteIndex(def); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateIndex(def.Index()); err != nil {
+		t.Fatal(err)
+	}
+	defs, err = db.GetIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 2 || !index.Matches(defs[1]) {
+		t.Errorf("Unexpected indexes after recreating: %+v", defs)
+	}
+}
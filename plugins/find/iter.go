@@ -0,0 +1,190 @@
+package find
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/flimzy/go-pouchdb"
+)
+
+// DefaultPageSize is the number of documents fetched at a time by FindIter,
+// unless another page size is given.
+const DefaultPageSize = 100
+
+// FindIter iterates over the results of a Find request, fetching them a page
+// at a time, and decoding one document at a time:
+//
+//    iter, err := db.FindIter(request, 0)
+//    if err != nil {
+//        return err
+//    }
+//    defer iter.Close()
+//    for iter.Next() {
+//        var doc MyDoc
+//        if err := iter.Scan(&doc); err != nil {
+//            return err
+//        }
+//        ...
+//    }
+//    if err := iter.Err(); err != nil {
+//        return err
+//    }
+//
+// Pages are requested with limit and skip, or with the bookmark returned by
+// the previous page, where the server supports bookmarks (CouchDB does,
+// pouchdb-find does not). As each page is a separate query, documents
+// changed during the iteration may be skipped or returned twice.
+type FindIter struct {
+	ctx      context.Context
+	db       *PouchPluginFind
+	req      map[string]interface{}
+	pageSize int
+	// skip and limit are those of the original request; limit is 0 if
+	// unlimited.
+	skip  int
+	limit int
+	// read is the number of documents fetched so far.
+	read     int
+	bookmark string
+	warning  string
+	docs     []json.RawMessage
+	doc      json.RawMessage
+	done     bool
+	err      error
+	closed   bool
+}
+
+// FindIter performs the requested search query, like Find, returning an
+// iterator over the matching documents. pageSize is the number of documents
+// fetched at a time; if it is 0, DefaultPageSize is used. The request's
+// Limit, if any, caps the total number of documents returned, and its Skip
+// applies to the first page. The first page is fetched before FindIter
+// returns.
+func (db *PouchPluginFind) FindIter(request interface{}, pageSize int) (*FindIter, error) {
+	return db.FindIterContext(context.Background(), request, pageSize)
+}
+
+// FindIterContext is like FindIter, but accepts a context, which applies to
+// the fetching of every page. Once ctx is cancelled, Next returns false, and
+// Err returns ctx.Err().
+func (db *PouchPluginFind) FindIterContext(ctx context.Context, request interface{}, pageSize int) (*FindIter, error) {
+	var req map[string]interface{}
+	if err := pouchdb.ConvertJSONObject(request, &req); err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	iter := &FindIter{
+		ctx:      ctx,
+		db:       db,
+		req:      req,
+		pageSize: pageSize,
+		skip:     intValue(req["skip"]),
+		limit:    intValue(req["limit"]),
+	}
+	if err := iter.fetch(); err != nil {
+		return nil, err
+	}
+	if db.advisor != nil {
+		// Only the first page is recorded, as the others are part of the
+		// same query.
+		db.advisor.record(req, iter.warning)
+	}
+	return iter, nil
+}
+
+// intValue returns v as an int, if it is a JSON number.
+func intValue(v interface{}) int {
+	f, _ := v.(float64)
+	return int(f)
+}
+
+// fetch fetches the next page of documents.
+func (it *FindIter) fetch() error {
+	size := it.pageSize
+	if it.limit > 0 && it.limit-it.read < size {
+		size = it.limit - it.read
+	}
+	it.req["limit"] = size
+	if it.bookmark != "" {
+		delete(it.req, "skip")
+		it.req["bookmark"] = it.bookmark
+	} else {
+		it.req["skip"] = it.skip + it.read
+	}
+	result, err := it.db.find(it.ctx, it.req)
+	if err != nil {
+		return err
+	}
+	var docs []json.RawMessage
+	if err := json.Unmarshal(result.Docs, &docs); err != nil {
+		return err
+	}
+	it.docs = docs
+	it.read += len(docs)
+	it.bookmark = result.Bookmark
+	if it.warning == "" {
+		it.warning = result.Warning
+	}
+	if len(docs) < size || (it.limit > 0 && it.read >= it.limit) {
+		it.done = true
+	}
+	return nil
+}
+
+// Next prepares the next document for reading with the Scan method, fetching
+// the next page if needed. It returns true on success, or false if there are
+// no more documents or an error occurred. Err should be consulted to
+// distinguish between the two cases.
+func (it *FindIter) Next() bool {
+	if it.closed {
+		return false
+	}
+	for len(it.docs) == 0 {
+		if it.done {
+			it.Close()
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			it.Close()
+			return false
+		}
+	}
+	it.doc = it.docs[0]
+	it.docs = it.docs[1:]
+	return true
+}
+
+// Scan unmarshals the current document into dest.
+func (it *FindIter) Scan(dest interface{}) error {
+	if it.doc == nil {
+		return errors.New("no current document; call Next first")
+	}
+	return json.Unmarshal(it.doc, dest)
+}
+
+// Err returns the error, if any, that was encountered during iteration.
+func (it *FindIter) Err() error {
+	return it.err
+}
+
+// Warning returns the first warning returned while fetching pages, if any,
+// such as when no index matches the request.
+func (it *FindIter) Warning() error {
+	if it.warning == "" {
+		return nil
+	}
+	return &pouchdb.Warning{Message: it.warning}
+}
+
+// Close stops the iteration, and releases the fetched documents. It is safe
+// to call Close more than once.
+func (it *FindIter) Close() error {
+	it.closed = true
+	it.docs = nil
+	it.doc = nil
+	return nil
+}
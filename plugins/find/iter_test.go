@@ -0,0 +1,97 @@
+// +build js
+
+package find_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flimzy/go-pouchdb"
+	"github.com/flimzy/go-pouchdb/plugins/find"
+)
+
+func TestFindIter(t *testing.T) {
+	mainDB := pouchdb.NewWithOpts("iterdb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	mainDB.Destroy(pouchdb.Options{}) // to ensure a clean slate
+	mainDB = pouchdb.NewWithOpts("iterdb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	defer mainDB.Destroy(pouchdb.Options{})
+	db := find.New(mainDB)
+	if err := db.CreateIndex(find.Index{Fields: []string{"n"}}); err != nil {
+		t.Fatal(err)
+	}
+	docs := make([]map[string]interface{}, 25)
+	for i := range docs {
+		docs[i] = map[string]interface{}{"n": i}
+	}
+	if _, err := mainDB.BulkDocs(docs, pouchdb.BulkDocsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(req find.FindRequest, pageSize int) []int {
+		iter, err := db.FindIter(req, pageSize)
+		if err != nil {
+			t.Fatalf("Error from FindIter: %s", err)
+		}
+		defer iter.Close()
+		var ns []int
+		for iter.Next() {
+			var doc struct {
+				N int `json:"n"`
+			}
+			if err := iter.Scan(&doc); err != nil {
+				t.Fatalf("Error from Scan: %s", err)
+			}
+			ns = append(ns, doc.N)
+		}
+		if err := iter.Err(); err != nil {
+			t.Fatalf("Error from iteration: %s", err)
+		}
+		if iter.Next() {
+			t.Errorf("Expected Next to return false after the end")
+		}
+		return ns
+	}
+	span := func(from, to int) []int {
+		var ns []int
+		for n := from; n < to; n++ {
+			ns = append(ns, n)
+		}
+		return ns
+	}
+
+	ns := collect(find.FindRequest{
+		Selector: find.Gte("n", 0),
+		Sort:     []find.SortField{find.Asc("n")},
+	}, 7)
+	if !reflect.DeepEqual(ns, span(0, 25)) {
+		t.Errorf("Unexpected documents: %v", ns)
+	}
+
+	ns = collect(find.FindRequest{
+		Selector: find.Gte("n", 0),
+		Sort:     []find.SortField{find.Asc("n")},
+		Skip:     2,
+		Limit:    15,
+	}, 10)
+	if !reflect.DeepEqual(ns, span(2, 17)) {
+		t.Errorf("Unexpected documents with skip and limit: %v", ns)
+	}
+
+	iter, err := db.FindIter(find.FindRequest{Selector: find.Eq("n", 100)}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := iter.Scan(&struct{}{}); err == nil {
+		t.Errorf("Expected an error from Scan before Next")
+	}
+	if iter.Next() {
+		t.Errorf("Expected no documents")
+	}
+	if err := iter.Close(); err != nil {
+		t.Errorf("Error from Close: %s", err)
+	}
+}
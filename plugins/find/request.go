@@ -0,0 +1,66 @@
+package find
+
+import "encoding/json"
+
+// FindRequest is a structured request for Find.
+//
+// See http://docs.couchdb.org/en/latest/api/database/find.html
+type FindRequest struct {
+	// Selector selects the documents to return. Required.
+	Selector Selector `json:"selector"`
+	// Fields limits the fields returned for each document. By default, all
+	// fields are returned.
+	Fields []string `json:"fields,omitempty"`
+	// Sort orders the results. The sort fields must be indexed.
+	Sort []SortField `json:"sort,omitempty"`
+	// Limit is the maximum number of documents to return.
+	Limit int `json:"limit,omitempty"`
+	// Skip is the number of documents to skip.
+	Skip int `json:"skip,omitempty"`
+	// UseIndex selects the index to use, by its design document name (the
+	// part after "_design/"), optionally followed by the index name.
+	UseIndex []string `json:"use_index,omitempty"`
+}
+
+// SortField is a field to sort by, in ascending or descending order.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Asc sorts by field in ascending order.
+func Asc(field string) SortField {
+	return SortField{Field: field}
+}
+
+// Desc sorts by field in descending order.
+func Desc(field string) SortField {
+	return SortField{Field: field, Desc: true}
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (s SortField) MarshalJSON() ([]byte, error) {
+	dir := "asc"
+	if s.Desc {
+		dir = "desc"
+	}
+	return json.Marshal(map[string]string{s.Field: dir})
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface. It accepts both a
+// bare field name and the {"field": "asc|desc"} form.
+func (s *SortField) UnmarshalJSON(data []byte) error {
+	var field string
+	if err := json.Unmarshal(data, &field); err == nil {
+		*s = SortField{Field: field}
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	for field, dir := range m {
+		*s = SortField{Field: field, Desc: dir == "desc"}
+	}
+	return nil
+}
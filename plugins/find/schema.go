@@ -0,0 +1,213 @@
+package find
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/flimzy/go-pouchdb"
+)
+
+// Schema describes the design documents and indexes a database is expected
+// to have.
+type Schema struct {
+	// DesignDocs are the expected design documents. Their Rev fields are
+	// ignored.
+	DesignDocs []*pouchdb.DesignDoc
+	// Indexes are the expected Mango indexes.
+	Indexes []Index
+}
+
+// SchemaChanges lists the names of the design documents or indexes affected
+// by EnsureSchema.
+type SchemaChanges struct {
+	Created   []string
+	Updated   []string
+	Removed   []string
+	Unchanged []string
+}
+
+// Changed returns true if anything was created, updated or removed.
+func (c *SchemaChanges) Changed() bool {
+	return len(c.Created)+len(c.Updated)+len(c.Removed) > 0
+}
+
+// SchemaReport describes the changes made by EnsureSchema.
+type SchemaReport struct {
+	// DesignDocs lists design documents by ID.
+	DesignDocs SchemaChanges
+	// Indexes lists indexes by name. Unnamed indexes which are created are
+	// listed by their comma-separated fields.
+	Indexes SchemaChanges
+}
+
+// Changed returns true if any design document or index was created, updated
+// or removed.
+func (r *SchemaReport) Changed() bool {
+	return r.DesignDocs.Changed() || r.Indexes.Changed()
+}
+
+// EnsureSchema brings the design documents and indexes of the database in
+// line with schema. Missing ones are created, those which differ are updated,
+// and any others are removed. If anything was updated or removed,
+// ViewCleanup() is then called to free the space used by stale view indexes.
+//
+// Indexes can't be changed in place, so an index with the same name as an
+// expected one but different fields is deleted and created again. An index
+// without a name matches any existing index with the same fields.
+//
+// EnsureSchema is idempotent, so it is safe to call each time an application
+// starts. It stops at the first error; the returned report then lists the
+// changes made so far.
+func EnsureSchema(db *PouchPluginFind, schema Schema) (*SchemaReport, error) {
+	return EnsureSchemaContext(context.Background(), db, schema)
+}
+
+// EnsureSchemaContext is like EnsureSchema, but accepts a context.
+func EnsureSchemaContext(ctx context.Context, db *PouchPluginFind, schema Schema) (*SchemaReport, error) {
+	report := &SchemaReport{}
+	if err := ensureDesignDocs(ctx, db, schema.DesignDocs, &report.DesignDocs); err != nil {
+		return report, err
+	}
+	if err := ensureIndexes(ctx, db, schema.Indexes, &report.Indexes); err != nil {
+		return report, err
+	}
+	if len(report.DesignDocs.Updated)+len(report.DesignDocs.Removed)+
+		len(report.Indexes.Updated)+len(report.Indexes.Removed) > 0 {
+		if err := db.ViewCleanupContext(ctx); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func ensureDesignDocs(ctx context.Context, db *PouchPluginFind, expected []*pouchdb.DesignDoc, changes *SchemaChanges) error {
+	ddocs, err := db.ListDesignDocsContext(ctx)
+	if err != nil {
+		return err
+	}
+	// The design documents of indexes are not listed; they are managed by
+	// ensureIndexes.
+	existing := make(map[string]*pouchdb.DesignDoc, len(ddocs))
+	for _, ddoc := range ddocs {
+		existing[ddoc.ID] = ddoc
+	}
+	for _, ddoc := range expected {
+		doc := *ddoc
+		doc.ID = pouchdb.DesignDocPrefix + ddoc.Name()
+		doc.Rev = ""
+		current, ok := existing[doc.ID]
+		delete(existing, doc.ID)
+		if ok {
+			equal, err := sameDesignDoc(&doc, current)
+			if err != nil {
+				return err
+			}
+			if equal {
+				changes.Unchanged = append(changes.Unchanged, doc.ID)
+				continue
+			}
+			doc.Rev = current.Rev
+		}
+		if _, err := db.PutDesignDocContext(ctx, &doc); err != nil {
+			return err
+		}
+		if ok {
+			changes.Updated = append(changes.Updated, doc.ID)
+		} else {
+			changes.Created = append(changes.Created, doc.ID)
+		}
+	}
+	for _, ddoc := range ddocs {
+		if _, stale := existing[ddoc.ID]; !stale {
+			continue
+		}
+		if _, err := db.DeleteDesignDocContext(ctx, ddoc.ID, ddoc.Rev); err != nil {
+			return err
+		}
+		changes.Removed = append(changes.Removed, ddoc.ID)
+	}
+	return nil
+}
+
+// sameDesignDoc compares the contents of two design documents, ignoring their
+// revisions.
+func sameDesignDoc(a, b *pouchdb.DesignDoc) (bool, error) {
+	docA, docB := *a, *b
+	docA.Rev, docB.Rev = "", ""
+	jsonA, err := json.Marshal(docA)
+	if err != nil {
+		return false, err
+	}
+	jsonB, err := json.Marshal(docB)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(jsonA, jsonB), nil
+}
+
+func ensureIndexes(ctx context.Context, db *PouchPluginFind, expected []Index, changes *SchemaChanges) error {
+	defs, err := db.GetIndexesContext(ctx)
+	if err != nil {
+		return err
+	}
+	var existing []*IndexDef
+	for _, def := range defs {
+		// The built-in _all_docs index has no design document
+		if def.Ddoc != "" {
+			existing = append(existing, def)
+		}
+	}
+	for _, index := range expected {
+		match := -1
+		for i, def := range existing {
+			if (index.Name != "" && index.Name == def.Name) ||
+				(index.Name == "" && index.Matches(def)) {
+				match = i
+				break
+			}
+		}
+		if match >= 0 {
+			def := existing[match]
+			existing = append(existing[:match], existing[match+1:]...)
+			if index.Matches(def) {
+				changes.Unchanged = append(changes.Unchanged, def.Name)
+				continue
+			}
+			if err := db.DeleteIndexContext(ctx, def); err != nil {
+				return err
+			}
+		}
+		if err := db.CreateIndexContext(ctx, index); err != nil && !IsIndexExists(err) {
+			return err
+		}
+		if match >= 0 {
+			changes.Updated = append(changes.Updated, index.Name)
+		} else {
+			changes.Created = append(changes.Created, index.label())
+		}
+	}
+	for _, def := range existing {
+		if err := db.DeleteIndexContext(ctx, def); err != nil {
+			return err
+		}
+		changes.Removed = append(changes.Removed, def.Name)
+	}
+	return nil
+}
+
+// label identifies the index in a SchemaReport.
+func (i *Index) label() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	if len(i.Sort) == 0 {
+		return strings.Join(i.Fields, ",")
+	}
+	names := make([]string, len(i.Sort))
+	for n, f := range i.Sort {
+		names[n] = f.Field
+	}
+	return strings.Join(names, ",")
+}
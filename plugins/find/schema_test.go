@@ -0,0 +1,85 @@
+// +build js
+
+package find_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flimzy/go-pouchdb"
+	"github.com/flimzy/go-pouchdb/plugins/find"
+)
+
+func TestEnsureSchema(t *testing.T) {
+	mainDB := pouchdb.NewWithOpts("schemadb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	mainDB.Destroy(pouchdb.Options{}) // to ensure a clean slate
+	mainDB = pouchdb.NewWithOpts("schemadb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	defer mainDB.Destroy(pouchdb.Options{})
+	db := find.New(mainDB)
+
+	ddoc := &pouchdb.DesignDoc{
+		ID: "app",
+		Views: map[string]*pouchdb.View{
+			"by_name": {Map: "function(doc) { emit(doc.name); }"},
+		},
+	}
+	schema := find.Schema{
+		DesignDocs: []*pouchdb.DesignDoc{ddoc},
+		Indexes: []find.Index{
+			{Name: "by-size", Fields: []string{"size"}},
+		},
+	}
+	check := func(name string, expected *find.SchemaReport) {
+		report, err := find.EnsureSchema(db, schema)
+		if err != nil {
+			t.Fatalf("%s: Error ensuring schema: %s", name, err)
+		}
+		if !reflect.DeepEqual(expected, report) {
+			t.Errorf("%s: Unexpected report.\nExpected: %+v\n  Actual: %+v", name, expected, report)
+		}
+	}
+
+	check("create", &find.SchemaReport{
+		DesignDocs: find.SchemaChanges{Created: []string{"_design/app"}},
+		Indexes:    find.SchemaChanges{Created: []string{"by-size"}},
+	})
+	// The index's design document must not trip up the second run
+	check("idempotent", &find.SchemaReport{
+		DesignDocs: find.SchemaChanges{Unchanged: []string{"_design/app"}},
+		Indexes:    find.SchemaChanges{Unchanged: []string{"by-size"}},
+	})
+
+	ddoc.Views["by_name"].Reduce = "_count"
+	schema.Indexes[0].Fields = []string{"size", "name"}
+	check("update", &find.SchemaReport{
+		DesignDocs: find.SchemaChanges{Updated: []string{"_design/app"}},
+		Indexes:    find.SchemaChanges{Updated: []string{"by-size"}},
+	})
+	stored, err := mainDB.GetDesignDoc("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Views["by_name"].Reduce != "_count" {
+		t.Errorf("Design doc was not updated: %+v", stored.Views["by_name"])
+	}
+
+	schema = find.Schema{}
+	report, err := find.EnsureSchema(db, schema)
+	if err != nil {
+		t.Fatalf("Error ensuring empty schema: %s", err)
+	}
+	expected := &find.SchemaReport{
+		DesignDocs: find.SchemaChanges{Removed: []string{"_design/app"}},
+		Indexes:    find.SchemaChanges{Removed: []string{"by-size"}},
+	}
+	if !reflect.DeepEqual(expected, report) {
+		t.Errorf("Unexpected report.\nExpected: %+v\n  Actual: %+v", expected, report)
+	}
+	if report, _ := find.EnsureSchema(db, schema); report.Changed() {
+		t.Errorf("Expected no changes, got %+v", report)
+	}
+}
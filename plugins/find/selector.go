@@ -0,0 +1,260 @@
+package find
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/flimzy/go-pouchdb"
+)
+
+// Selector is a Mango selector, as used by Find. Selectors are built with
+// the functions of this package, which check the operators at compile time:
+//
+//    find.And(
+//        find.Eq("type", "post"),
+//        find.Gte("year", 2015),
+//        find.ElemMatch("tags", find.In("", "go", "gopherjs")),
+//    )
+//
+// Conditions with an empty field name apply to the value being matched
+// itself, such as an element of an array within ElemMatch.
+//
+// See http://docs.couchdb.org/en/latest/api/database/find.html#selector-syntax
+type Selector map[string]interface{}
+
+// FieldType is a JSON type, as used by Type.
+type FieldType string
+
+// The JSON types.
+const (
+	NullType    FieldType = "null"
+	BooleanType FieldType = "boolean"
+	NumberType  FieldType = "number"
+	StringType  FieldType = "string"
+	ArrayType   FieldType = "array"
+	ObjectType  FieldType = "object"
+)
+
+// condition builds the selector {field: {op: arg}}, or {op: arg} if field
+// is empty.
+func condition(field, op string, arg interface{}) Selector {
+	cond := Selector{op: arg}
+	if field == "" {
+		return cond
+	}
+	return Selector{field: cond}
+}
+
+// Eq matches documents whose field equals value.
+func Eq(field string, value interface{}) Selector {
+	return condition(field, "$eq", value)
+}
+
+// Ne matches documents whose field does not equal value.
+func Ne(field string, value interface{}) Selector {
+	return condition(field, "$ne", value)
+}
+
+// Gt matches documents whose field is greater than value.
+func Gt(field string, value interface{}) Selector {
+	return condition(field, "$gt", value)
+}
+
+// Gte matches documents whose field is greater than or equal to value.
+func Gte(field string, value interface{}) Selector {
+	return condition(field, "$gte", value)
+}
+
+// Lt matches documents whose field is less than value.
+func Lt(field string, value interface{}) Selector {
+	return condition(field, "$lt", value)
+}
+
+// Lte matches documents whose field is less than or equal to value.
+func Lte(field string, value interface{}) Selector {
+	return condition(field, "$lte", value)
+}
+
+// In matches documents whose field equals any of values.
+func In(field string, values ...interface{}) Selector {
+	return condition(field, "$in", nonNil(values))
+}
+
+// Nin matches documents whose field equals none of values.
+func Nin(field string, values ...interface{}) Selector {
+	return condition(field, "$nin", nonNil(values))
+}
+
+// All matches documents whose field is an array containing all of values.
+func All(field string, values ...interface{}) Selector {
+	return condition(field, "$all", nonNil(values))
+}
+
+// Exists matches documents which have the field, or which lack it if exists
+// is false.
+func Exists(field string, exists bool) Selector {
+	return condition(field, "$exists", exists)
+}
+
+// Type matches documents whose field is of the given JSON type.
+func Type(field string, t FieldType) Selector {
+	return condition(field, "$type", t)
+}
+
+// Size matches documents whose field is an array of the given length.
+func Size(field string, length int) Selector {
+	return condition(field, "$size", length)
+}
+
+// Mod matches documents whose field is a number which, divided by divisor,
+// leaves remainder.
+func Mod(field string, divisor, remainder int) Selector {
+	return condition(field, "$mod", []int{divisor, remainder})
+}
+
+// Regex matches documents whose field is a string matching the regular
+// expression pattern. The pattern is evaluated by JavaScript, not by Go's
+// regexp package.
+func Regex(field, pattern string) Selector {
+	return condition(field, "$regex", pattern)
+}
+
+// ElemMatch matches documents whose field is an array with at least one
+// element matching sel.
+func ElemMatch(field string, sel Selector) Selector {
+	return condition(field, "$elemMatch", sel)
+}
+
+// And matches documents matching all of sels.
+func And(sels ...Selector) Selector {
+	return Selector{"$and": nonNilSelectors(sels)}
+}
+
+// Or matches documents matching any of sels.
+func Or(sels ...Selector) Selector {
+	return Selector{"$or": nonNilSelectors(sels)}
+}
+
+// Nor matches documents matching none of sels.
+func Nor(sels ...Selector) Selector {
+	return Selector{"$nor": nonNilSelectors(sels)}
+}
+
+// Not matches documents not matching sel.
+func Not(sel Selector) Selector {
+	return Selector{"$not": sel}
+}
+
+// MatchAll matches every document, as {"_id": {"$gt": null}}. A selector is
+// required, so this is needed to fetch documents by sort order alone.
+func MatchAll() Selector {
+	return Gt("_id", nil)
+}
+
+// nonNil makes sure empty lists are sent as [], rather than null.
+func nonNil(values []interface{}) []interface{} {
+	if values == nil {
+		return []interface{}{}
+	}
+	return values
+}
+
+func nonNilSelectors(sels []Selector) []Selector {
+	if sels == nil {
+		return []Selector{}
+	}
+	return sels
+}
+
+// sameSelector returns true if a and b are the same selector, once
+// normalized. Nil and empty selectors are the same.
+func sameSelector(a, b Selector) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	jsonA, err := normalizedJSON(a)
+	if err != nil {
+		return false
+	}
+	jsonB, err := normalizedJSON(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(jsonA, jsonB)
+}
+
+// normalizedJSON encodes the normalized form of sel. encoding/json sorts the
+// keys of maps, so equal selectors have equal encodings.
+func normalizedJSON(sel Selector) ([]byte, error) {
+	// Round-trip through JSON first, so nested values have the same types
+	// whether sel was built in Go or read from the database.
+	var raw map[string]interface{}
+	if err := pouchdb.ConvertJSONObject(sel, &raw); err != nil {
+		return nil, err
+	}
+	return json.Marshal(normalizeSelector(raw))
+}
+
+// normalizeSelector rewrites sel the way pouchdb-find does before storing
+// it: implicit equality becomes $eq, and the selectors of a top-level $and
+// are merged into sel when none of their fields clash.
+func normalizeSelector(sel map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(sel))
+	for key, value := range sel {
+		switch {
+		case key == "$and":
+			// Merged below
+		case key == "$or" || key == "$nor":
+			subs, _ := value.([]interface{})
+			normalized := make([]interface{}, len(subs))
+			for i, sub := range subs {
+				normalized[i] = sub
+				if s, ok := asSelector(sub); ok {
+					normalized[i] = normalizeSelector(s)
+				}
+			}
+			result[key] = normalized
+		case key == "$not":
+			if s, ok := asSelector(value); ok {
+				value = normalizeSelector(s)
+			}
+			result[key] = value
+		case strings.HasPrefix(key, "$"):
+			result[key] = value
+		default:
+			if _, ok := asSelector(value); !ok {
+				value = map[string]interface{}{"$eq": value}
+			}
+			result[key] = value
+		}
+	}
+	and, ok := sel["$and"]
+	if !ok {
+		return result
+	}
+	subs, _ := and.([]interface{})
+	merged := make(map[string]interface{})
+	for _, sub := range subs {
+		s, ok := asSelector(sub)
+		if !ok {
+			result["$and"] = and
+			return result
+		}
+		for key, value := range normalizeSelector(s) {
+			if _, clash := merged[key]; clash {
+				result["$and"] = and
+				return result
+			}
+			if _, clash := result[key]; clash {
+				result["$and"] = and
+				return result
+			}
+			merged[key] = value
+		}
+	}
+	for key, value := range merged {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+// +build js
+
+package find_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/flimzy/go-pouchdb"
+	"github.com/flimzy/go-pouchdb/plugins/find"
+)
+
+func TestSelectors(t *testing.T) {
+	tests := []struct {
+		name     string
+		sel      find.Selector
+		expected string
+	}{
+		{"Eq", find.Eq("name", "Bob"), `{"name":{"$eq":"Bob"}}`},
+		{"Ne", find.Ne("name", "Bob"), `{"name":{"$ne":"Bob"}}`},
+		{"Gt", find.Gt("size", 10), `{"size":{"$gt":10}}`},
+		{"Gte", find.Gte("size", 10), `{"size":{"$gte":10}}`},
+		{"Lt", find.Lt("size", 10), `{"size":{"$lt":10}}`},
+		{"Lte", find.Lte("size", 10), `{"size":{"$lte":10}}`},
+		{"In", find.In("name", "Alice", "Bob"), `{"name":{"$in":["Alice","Bob"]}}`},
+		{"In empty", find.In("name"), `{"name":{"$in":[]}}`},
+		{"Nin", find.Nin("name", "Alice"), `{"name":{"$nin":["Alice"]}}`},
+		{"All", find.All("tags", "a", "b"), `{"tags":{"$all":["a","b"]}}`},
+		{"Exists", find.Exists("size", false), `{"size":{"$exists":false}}`},
+		{"Type", find.Type("size", find.NumberType), `{"size":{"$type":"number"}}`},
+		{"Size", find.Size("tags", 2), `{"tags":{"$size":2}}`},
+		{"Mod", find.Mod("size", 4, 1), `{"size":{"$mod":[4,1]}}`},
+		{"Regex", find.Regex("name", "^B"), `{"name":{"$regex":"^B"}}`},
+		{"ElemMatch", find.ElemMatch("tags", find.Eq("", "go")), `{"tags":{"$elemMatch":{"$eq":"go"}}}`},
+		{"And", find.And(find.Eq("a", 1), find.Eq("b", 2)), `{"$and":[{"a":{"$eq":1}},{"b":{"$eq":2}}]}`},
+		{"Or", find.Or(find.Eq("a", 1)), `{"$or":[{"a":{"$eq":1}}]}`},
+		{"Nor", find.Nor(), `{"$nor":[]}`},
+		{"Not", find.Not(find.Eq("a", 1)), `{"$not":{"a":{"$eq":1}}}`},
+		{"MatchAll", find.MatchAll(), `{"_id":{"$gt":null}}`},
+	}
+	for _, test := range tests {
+		result, err := json.Marshal(test.sel)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if string(result) != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, result)
+		}
+	}
+}
+
+func TestFindRequest(t *testing.T) {
+	req := find.FindRequest{
+		Selector: find.Eq("name", "Bob"),
+		Fields:   []string{"_id", "name"},
+		Sort:     []find.SortField{find.Asc("name"), find.Desc("size")},
+		Limit:    10,
+		Skip:     5,
+		UseIndex: []string{"idx", "by-name"},
+	}
+	result, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"selector":{"name":{"$eq":"Bob"}},"fields":["_id","name"],"sort":[{"name":"asc"},{"size":"desc"}],"limit":10,"skip":5,"use_index":["idx","by-name"]}`
+	if string(result) != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+	var parsed find.FindRequest
+	if err := json.Unmarshal([]byte(`{"selector":{},"sort":["name",{"size":"desc"}]}`), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed.Sort, []find.SortField{find.Asc("name"), find.Desc("size")}) {
+		t.Errorf("Unexpected sort: %v", parsed.Sort)
+	}
+}
+
+func TestFindWithRequest(t *testing.T) {
+	mainDB := pouchdb.NewWithOpts("selectordb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	mainDB.Destroy(pouchdb.Options{}) // to ensure a clean slate
+	mainDB = pouchdb.NewWithOpts("selectordb", pouchdb.DBOptions{
+		DB: memdown,
+	})
+	defer mainDB.Destroy(pouchdb.Options{})
+	db := find.New(mainDB)
+	if err := db.CreateIndex(find.Index{Fields: []string{"size"}}); err != nil {
+		t.Fatal(err)
+	}
+	docs := []map[string]interface{}{
+		{"_id": "a", "size": 1, "tags": []string{"go"}},
+		{"_id": "b", "size": 2, "tags": []string{"js"}},
+		{"_id": "c", "size": 3, "tags": []string{"go", "js"}},
+	}
+	if _, err := mainDB.BulkDocs(docs, pouchdb.BulkDocsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var results []struct {
+		ID string `json:"_id"`
+	}
+	err := db.Find(find.FindRequest{
+		Selector: find.And(
+			find.Gte("size", 1),
+			find.ElemMatch("tags", find.Eq("", "go")),
+		),
+		Fields: []string{"_id"},
+		Sort:   []find.SortField{find.Desc("size")},
+	}, &results)
+	if err != nil {
+		t.Fatalf("Error from Find: %s", err)
+	}
+	if len(results) != 2 || results[0].ID != "c" || results[1].ID != "a" {
+		t.Errorf("Unexpected results: %v", results)
+	}
+}
@@ -1,16 +1,12 @@
 package pouchdb
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"io"
 	"reflect"
-	"strings"
 
-	"github.com/flimzy/jsblob"
 	"github.com/gopherjs/gopherjs/js"
-	"github.com/gopherjs/jsbuiltin"
 )
 
 type PouchDB struct {
@@ -68,7 +64,7 @@ func New(db_name string) *PouchDB {
 
 // NewWithOpts creates a database or opens an existing one.
 // See: http://pouchdb.com/api.html#create_database
-func NewWithOpts(db_name string, opts Options) *PouchDB {
+func NewWithOpts(db_name string, opts DBOptions) *PouchDB {
 	return &PouchDB{globalPouch().New(db_name, opts.compile())}
 }
 
@@ -76,7 +72,15 @@ func NewWithOpts(db_name string, opts Options) *PouchDB {
 //
 // See: http://pouchdb.com/api.html#database_information
 func (db *PouchDB) Info() (DBInfo, error) {
-	rw := NewResultWaiter()
+	return db.InfoContext(context.Background())
+}
+
+// InfoContext is like Info, but accepts a context.
+func (db *PouchDB) InfoContext(ctx context.Context) (DBInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return DBInfo{}, err
+	}
+	rw := NewResultWaiterContext(ctx)
 	db.Call("info", rw.Done)
 	result, err := rw.ReadResult()
 	if err != nil {
@@ -90,7 +94,15 @@ func (db *PouchDB) Info() (DBInfo, error) {
 // Deestroy will delete the database.
 // See: http://pouchdb.com/api.html#delete_database
 func (db *PouchDB) Destroy(opts Options) error {
-	rw := NewResultWaiter()
+	return db.DestroyContext(context.Background(), opts)
+}
+
+// DestroyContext is like Destroy, but accepts a context.
+func (db *PouchDB) DestroyContext(ctx context.Context, opts Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rw := NewResultWaiterContext(ctx)
 	db.Call("destroy", opts.compile(), rw.Done)
 	return rw.Error()
 }
@@ -116,9 +128,19 @@ func ConvertJSObject(jsObj *js.Object, output interface{}) error {
 // Put will create a new document or update an existing document.
 // See: http://pouchdb.com/api.html#create_document
 func (db *PouchDB) Put(doc interface{}) (newrev string, err error) {
+	return db.PutContext(context.Background(), doc)
+}
+
+// PutContext is like Put, but accepts a context.
+func (db *PouchDB) PutContext(ctx context.Context, doc interface{}) (newrev string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	var convertedDoc interface{}
-	ConvertJSONObject(doc, &convertedDoc)
-	rw := NewResultWaiter()
+	if err := ConvertJSONObject(doc, &convertedDoc); err != nil {
+		return "", err
+	}
+	rw := NewResultWaiterContext(ctx)
 	db.Call("put", convertedDoc, rw.Done)
 	return rw.ReadRev()
 }
@@ -131,8 +153,16 @@ func (db *PouchDB) Put(doc interface{}) (newrev string, err error) {
 //
 // See http://pouchdb.com/api.html#fetch_document
 // and http://docs.couchdb.org/en/latest/api/document/common.html?highlight=doc#get--db-docid
-func (db *PouchDB) Get(docId string, doc interface{}, opts Options) error {
-	rw := NewResultWaiter()
+func (db *PouchDB) Get(docId string, doc interface{}, opts GetOptions) error {
+	return db.GetContext(context.Background(), docId, doc, opts)
+}
+
+// GetContext is like Get, but accepts a context.
+func (db *PouchDB) GetContext(ctx context.Context, docId string, doc interface{}, opts GetOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rw := NewResultWaiterContext(ctx)
 	db.Call("get", docId, opts.compile(), rw.Done)
 	obj, err := rw.ReadResult()
 	if err != nil {
@@ -141,96 +171,25 @@ func (db *PouchDB) Get(docId string, doc interface{}, opts Options) error {
 	return ConvertJSONObject(obj, doc)
 }
 
-// Attachment represents document attachments.
-// This structure is borrowed from http://godoc.org/github.com/fjl/go-couchdb#Attachment
-type Attachment struct {
-	Name string    // File name
-	Type string    // MIME type of the Body
-	MD5  []byte    // MD5 checksum of the Body
-	Body io.Reader // The body itself
-}
-
-// PutAttachment creates or updates an attachment. To create an attachment
-// on a non-existing document, pass an empty rev.
-//
-// See http://pouchdb.com/api.html#save_attachment and
-// http://godoc.org/github.com/fjl/go-couchdb#DB.PutAttachment
-func (db *PouchDB) PutAttachment(docid string, att *Attachment, rev string) (newrev string, err error) {
-	rw := NewResultWaiter()
-	db.Call("putAttachment", docid, att.Name, rev, attachmentObject(att), att.Type, rw.Done)
-	return rw.ReadRev()
-}
-
-// attachmentObject converts an io.Reader to a JavaScript Buffer in node, or
-// a Blob in the browser
-func attachmentObject(att *Attachment) *js.Object {
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(att.Body)
-	if buffer := js.Global.Get("Buffer"); jsbuiltin.TypeOf(buffer) == "function" {
-		// The Buffer type is supported, so we'll use that
-		return buffer.New(buf.String())
-	}
-	// We must be in the browser, so return a Blob instead
-	return js.Global.Get("Blob").New([]interface{}{buf.Bytes()}, map[string]string{"type": att.Type})
-}
-
-func attachmentFromPouch(name string, obj *js.Object) *Attachment {
-	att := &Attachment{
-		Name: name,
-	}
-	var body string
-	if jsbuiltin.TypeOf(obj.Get("write")) == "function" {
-		// This looks like a Buffer object; we're in node
-		body = obj.Call("toString", "utf-8").String()
-		att.Body = strings.NewReader(body) // FIXME: bytes, not string
-	} else {
-		// We're in the browser
-		att.Type = obj.Get("type").String()
-		blob := jsblob.Blob{*obj}
-		att.Body = bytes.NewReader(blob.Bytes())
-	}
-	return att
-}
-
-// Attachment retrieves an attachment. The rev argument can be left empty to
-// retrieve the latest revision. The caller is responsible for closing the
-// attachment's Body if the returned error is nil.
-//
-// Note that PouchDB's getDocument() does not fetch meta data (except for the
-// MIME type in the browser only), so the MD5 sum and (in node) the content
-// type fields will be empty.
-//
-// See http://pouchdb.com/api.html#get_attachment and
-// http://godoc.org/github.com/fjl/go-couchdb#Attachment
-func (db *PouchDB) Attachment(docid, name, rev string) (*Attachment, error) {
-	opts := Options{
-		Rev: rev,
-	}
-	rw := NewResultWaiter()
-	db.Call("getAttachment", docid, name, opts.compile(), rw.Done)
-	obj, err := rw.Read()
-	if err != nil {
-		return nil, err
-	}
-	x := attachmentFromPouch(name, obj)
-	return x, nil
-}
-
-func (db *PouchDB) DeleteAttachment(docid, name, rev string) (newrev string, err error) {
-	rw := NewResultWaiter()
-	db.Call("removeAttachment", docid, name, rev, rw.Done)
-	return rw.ReadRev()
-}
-
 // Remove will delete the document. The document must specify both _id and
 // _rev. On success, it returns the _rev of the new document with _delete set
 // to true.
 //
 // See: http://pouchdb.com/api.html#delete_document
 func (db *PouchDB) Remove(doc interface{}, opts Options) (newrev string, err error) {
+	return db.RemoveContext(context.Background(), doc, opts)
+}
+
+// RemoveContext is like Remove, but accepts a context.
+func (db *PouchDB) RemoveContext(ctx context.Context, doc interface{}, opts Options) (newrev string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	var convertedDoc interface{}
-	ConvertJSONObject(doc, &convertedDoc)
-	rw := NewResultWaiter()
+	if err := ConvertJSONObject(doc, &convertedDoc); err != nil {
+		return "", err
+	}
+	rw := NewResultWaiterContext(ctx)
 	db.Call("remove", convertedDoc, opts.compile(), rw.Done)
 	return rw.ReadRev()
 }
@@ -238,103 +197,202 @@ func (db *PouchDB) Remove(doc interface{}, opts Options) (newrev string, err err
 // BulkDocs will create, update or delete multiple documents.
 //
 // See: http://pouchdb.com/api.html#batch_create
-func (db *PouchDB) BulkDocs(docs interface{}, opts Options) ([]Result, error) {
+func (db *PouchDB) BulkDocs(docs interface{}, opts BulkDocsOptions) ([]Result, error) {
+	return db.BulkDocsContext(context.Background(), docs, opts)
+}
+
+// BulkDocsContext is like BulkDocs, but accepts a context.
+func (db *PouchDB) BulkDocsContext(ctx context.Context, docs interface{}, opts BulkDocsOptions) ([]Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s := reflect.ValueOf(docs)
 	if s.Kind() != reflect.Slice {
 		return nil, errors.New("docs must be a slice")
 	}
 	convertedDocs := make([]interface{}, s.Len())
 	for i := 0; i < s.Len(); i++ {
-		ConvertJSONObject(s.Index(i).Interface(), &(convertedDocs[i]))
+		if err := ConvertJSONObject(s.Index(i).Interface(), &(convertedDocs[i])); err != nil {
+			return nil, err
+		}
 	}
-	rw := NewResultWaiter()
+	rw := NewResultWaiterContext(ctx)
 	db.Call("bulkDocs", convertedDocs, opts.compile(), rw.Done)
 	return rw.ReadBulkResults()
 }
 
 // AllDocs will fetch multiple documents.
-// The output of the query is unmarshalled into the given result. The format
-// of the result depends on the options. Please refer to the CouchDB HTTP API
+// The rows of the result may be read with its Rows iterator. The contents of
+// each row depend on the options. Please refer to the CouchDB HTTP API
 // documentation for all the possible options that can be set.
 //
 // See http://pouchdb.com/api.html#batch_fetch and
 // http://docs.couchdb.org/en/latest/api/database/bulk-api.html#db-all-docs
-func (db *PouchDB) AllDocs(result interface{}, opts Options) error {
-	rw := NewResultWaiter()
+func (db *PouchDB) AllDocs(opts AllDocsOptions) (*ViewResult, error) {
+	return db.AllDocsContext(context.Background(), opts)
+}
+
+// AllDocsContext is like AllDocs, but accepts a context.
+func (db *PouchDB) AllDocsContext(ctx context.Context, opts AllDocsOptions) (*ViewResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rw := NewResultWaiterContext(ctx)
 	db.Call("allDocs", opts.compile(), rw.Done)
 	obj, err := rw.Read()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return ConvertJSObject(obj, &result)
+	return newViewResult(obj)
 }
 
 // Invoke a map/reduce function, which allows you to perform more complex
 // queries on PouchDB than what you get with allDocs().
 //
 // See http://pouchdb.com/api.html#query_database
-func (db *PouchDB) Query(view string, result interface{}, opts Options) error {
-	rw := NewResultWaiter()
-	db.Call("query", view, opts.compile(), rw.Done)
+func (db *PouchDB) Query(view string, opts QueryOptions) (*ViewResult, error) {
+	return db.QueryContext(context.Background(), view, opts)
+}
+
+// QueryContext is like Query, but accepts a context.
+func (db *PouchDB) QueryContext(ctx context.Context, view string, opts QueryOptions) (*ViewResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.Reduce != nil && !opts.NoReduce {
+		return db.queryGoReduce(ctx, view, opts)
+	}
+	o, err := opts.compile()
+	if err != nil {
+		return nil, err
+	}
+	rw := NewResultWaiterContext(ctx)
+	db.Call("query", view, o, rw.Done)
 	obj, err := rw.Read()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return ConvertJSObject(obj, &result)
+	return newViewResult(obj)
+}
+
+// queryGoReduce fetches the mapped rows of a view, and reduces them with
+// opts.Reduce.
+func (db *PouchDB) queryGoReduce(ctx context.Context, view string, opts QueryOptions) (*ViewResult, error) {
+	mapOpts := opts
+	mapOpts.Reduce = nil
+	mapOpts.NoReduce = true
+	mapOpts.Group, mapOpts.GroupLevel = false, 0
+	mapOpts.Limit, mapOpts.Skip = 0, 0
+	mapOpts.IncludeDocs = false
+	result, err := db.QueryContext(ctx, view, mapOpts)
+	if err != nil {
+		return nil, err
+	}
+	reduced, err := reduceRows(result.Rows, opts.Reduce, opts)
+	if err != nil {
+		return nil, err
+	}
+	reduced.UpdateSeq = result.UpdateSeq
+	return reduced, nil
 }
 
-type MapFunc func(string)
+// QueryFunc queries a temporary view, whose map function is written in Go.
+// Temporary views are built from scratch for every query, so they are only
+// suitable for ad-hoc queries on small databases.
+//
+// See http://pouchdb.com/api.html#query_database
+func (db *PouchDB) QueryFunc(fn MapFunc, opts QueryOptions) (*ViewResult, error) {
+	return db.QueryFuncContext(context.Background(), fn, opts)
+}
 
-func (db *PouchDB) QueryFunc(fn MapFunc, result interface{}, opts Options) error {
-	rw := NewResultWaiter()
-	db.Call("query", fn, opts.compile(), rw.Done)
+// QueryFuncContext is like QueryFunc, but accepts a context.
+func (db *PouchDB) QueryFuncContext(ctx context.Context, fn MapFunc, opts QueryOptions) (*ViewResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	o, err := opts.compile()
+	if err != nil {
+		return nil, err
+	}
+	var fun interface{} = fn.jsFunc()
+	var reducer *jsReducer
+	if opts.Reduce != nil && !opts.NoReduce {
+		reducer = newJSReducer(opts.Reduce)
+		fun = map[string]interface{}{
+			"map":    fun,
+			"reduce": reducer.jsFunc(),
+		}
+	}
+	rw := NewResultWaiterContext(ctx)
+	done := rw.Done
+	if reducer != nil {
+		// PouchDB may still call the reducer after ctx is cancelled, so it
+		// is only released once the query has settled.
+		done = func(err, result *js.Object) {
+			reducer.release()
+			rw.Done(err, result)
+		}
+	}
+	db.Call("query", fun, o, done)
 	obj, err := rw.Read()
+	if err != nil && err == ctx.Err() {
+		return nil, err
+	}
+	if reducer != nil && reducer.err != nil {
+		return nil, reducer.err
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return ConvertJSObject(obj, &result)
+	return newViewResult(obj)
 }
 
 // Replicate will replicate data from source to target in the foreground.
-// For "live" replication use ReplicateLive()
+// For "live" replication use ReplicateLive().
+//
 // See: http://pouchdb.com/api.html#replication
-func Replicate(source, target *PouchDB, opts Options) (Result, error) {
-	rw := NewResultWaiter()
-	repl := globalPouch().Call("replicate", source, target, opts.compile())
+func Replicate(source, target *PouchDB, opts ReplicateOptions) (Result, error) {
+	return ReplicateContext(context.Background(), source, target, opts)
+}
+
+// ReplicateContext is like Replicate, but accepts a context. If ctx is
+// cancelled, the replication is cancelled as well.
+func ReplicateContext(ctx context.Context, source, target *PouchDB, opts ReplicateOptions) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	o := opts.compile()
+	// One-shot replication only; ReplicateLive() handles live replication.
+	delete(o, "live")
+	delete(o, "retry")
+	rw := NewResultWaiterContext(ctx)
+	repl := globalPouch().Call("replicate", source, target, o)
 	repl.Call("then", func(r *js.Object) {
 		rw.Done(nil, r)
 	})
 	repl.Call("catch", func(e *js.Object) {
 		rw.Done(e, nil)
 	})
-	return rw.ReadResult()
-}
-
-// Sync data from src to target and target to src. This is a convenience method for bidirectional data replication.
-//
-// See http://pouchdb.com/api.html#sync
-func Sync(source, target *PouchDB, opts Options) ([]Result, error) {
-	results := make([]Result, 2)
-	result, err := Replicate(source, target, opts)
-	results[0] = result
-	if err != nil {
-		return results, err
+	result, err := rw.ReadResult()
+	if err != nil && err == ctx.Err() {
+		repl.Call("cancel")
 	}
-	result, err = Replicate(target, source, opts)
-	results[1] = result
-	return results, err
+	return result, err
 }
 
-// Replicate will replicate data from source to target in the background.
-// This method returns a *ChangeFeed which can be used to monitor progress
-// in a Go routine. For foreground sync, use Replicate().
-// See: http://pouchdb.com/api.html#replication
-
 // ViewCleanup cleans up any stale map/reduce indexes.
 //
 // See: http://pouchdb.com/api.html#view_cleanup
 func (db *PouchDB) ViewCleanup() error {
-	rw := NewResultWaiter()
+	return db.ViewCleanupContext(context.Background())
+}
+
+// ViewCleanupContext is like ViewCleanup, but accepts a context.
+func (db *PouchDB) ViewCleanupContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rw := NewResultWaiterContext(ctx)
 	db.Call("viewCleanup", rw.Done)
 	return rw.Error()
 }
@@ -342,18 +400,137 @@ func (db *PouchDB) ViewCleanup() error {
 // Compact triggers a compaction operation in the local or remote database.
 //
 // See: http://pouchdb.com/api.html#compaction
-func (db *PouchDB) Compact(opts Options) error {
-	rw := NewResultWaiter()
-	db.Call("compact", opts, rw.Done)
+func (db *PouchDB) Compact(opts CompactOptions) error {
+	return db.CompactContext(context.Background(), opts)
+}
+
+// CompactContext is like Compact, but accepts a context.
+func (db *PouchDB) CompactContext(ctx context.Context, opts CompactOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rw := NewResultWaiterContext(ctx)
+	db.Call("compact", opts.compile(), rw.Done)
 	return rw.Error()
 }
 
+// RevsDiffResult describes the revisions of a single document which are
+// missing from the database, as returned by RevsDiff.
+type RevsDiffResult struct {
+	Missing           []string `json:"missing"`
+	PossibleAncestors []string `json:"possible_ancestors,omitempty"`
+}
+
 // RevsDiff will, given a set of document/revision IDs return the subset of
-// those that do not correspond to revisions stored in the database.
+// those that do not correspond to revisions stored in the database. The
+// result is keyed by document ID; documents for which all revisions are
+// already stored are omitted.
+//
 // See: http://pouchdb.com/api.html#revisions_diff
-// func (db *PouchDB) RevsDiff(diff *js.Object, fn interface{}) {
-// 	db.Call("revsDiff", diff, fn)
-// }
+func (db *PouchDB) RevsDiff(diff map[string][]string) (map[string]RevsDiffResult, error) {
+	return db.RevsDiffContext(context.Background(), diff)
+}
+
+// RevsDiffContext is like RevsDiff, but accepts a context.
+func (db *PouchDB) RevsDiffContext(ctx context.Context, diff map[string][]string) (map[string]RevsDiffResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rw := NewResultWaiterContext(ctx)
+	db.Call("revsDiff", diff, rw.Done)
+	obj, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]RevsDiffResult)
+	err = ConvertJSObject(obj, &result)
+	return result, err
+}
+
+// BulkGetRequest identifies a single document revision to be fetched by
+// BulkGet. If Rev is empty, the winning revision is fetched.
+type BulkGetRequest struct {
+	ID  string `json:"id"`
+	Rev string `json:"rev,omitempty"`
+	// AttsSince lists revisions the caller already has; attachments which
+	// have not changed since any of them are returned as stubs.
+	AttsSince []string `json:"atts_since,omitempty"`
+}
+
+// BulkGetResult contains the revisions fetched for a single requested
+// document.
+type BulkGetResult struct {
+	ID   string       `json:"id"`
+	Docs []BulkGetDoc `json:"docs"`
+}
+
+// BulkGetDoc is a single revision returned by BulkGet. Exactly one of OK or
+// Error is set.
+type BulkGetDoc struct {
+	// OK contains the raw document. Use ScanDoc to decode it.
+	OK    json.RawMessage `json:"ok,omitempty"`
+	Error *BulkGetError   `json:"error,omitempty"`
+}
+
+// ScanDoc unmarshals the fetched revision into doc, or returns the error
+// reported for it.
+func (d *BulkGetDoc) ScanDoc(doc interface{}) error {
+	if d.Error != nil {
+		return d.Error
+	}
+	return json.Unmarshal(d.OK, doc)
+}
+
+// BulkGetError describes a revision which could not be fetched by BulkGet.
+type BulkGetError struct {
+	ID     string `json:"id"`
+	Rev    string `json:"rev"`
+	Name   string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// Error satisfies the error interface for the BulkGetError type
+func (e *BulkGetError) Error() string {
+	return e.ID + "@" + e.Rev + ": " + e.Name + ": " + e.Reason
+}
+
+type bulkGetResponse struct {
+	Results []BulkGetResult `json:"results"`
+}
+
+// BulkGet fetches the requested revisions of multiple documents in a single
+// request. Revs and Attachments are honored in opts.
+//
+// See: https://pouchdb.com/api.html#bulk_get
+func (db *PouchDB) BulkGet(docs []BulkGetRequest, opts BulkGetOptions) ([]BulkGetResult, error) {
+	return db.BulkGetContext(context.Background(), docs, opts)
+}
+
+// BulkGetContext is like BulkGet, but accepts a context.
+func (db *PouchDB) BulkGetContext(ctx context.Context, docs []BulkGetRequest, opts BulkGetOptions) ([]BulkGetResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var request map[string]interface{}
+	err := ConvertJSONObject(struct {
+		Docs []BulkGetRequest `json:"docs"`
+	}{docs}, &request)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range opts.compile() {
+		request[k] = v
+	}
+	rw := NewResultWaiterContext(ctx)
+	db.Call("bulkGet", request, rw.Done)
+	obj, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	var response bulkGetResponse
+	err = ConvertJSObject(obj, &response)
+	return response.Results, err
+}
 
 // Call calls the underlying PouchDB object's method with the given name and
 // arguments. This method is used internally, and may also facilitate the use
@@ -2,9 +2,20 @@ package pouchdb
 
 import (
 	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
 	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/gopherjs/gopherjs/js"
 )
@@ -24,7 +35,7 @@ func init() {
 }
 
 func newPouch(dbname string) *PouchDB {
-	return NewWithOpts(dbname, Options{
+	return NewWithOpts(dbname, DBOptions{
 		DB: memdown,
 	})
 }
@@ -42,7 +53,7 @@ func TestNew(t *testing.T) {
 }
 
 func TestNewFromOpts(t *testing.T) {
-	db := NewWithOpts("testdb", Options{})
+	db := NewWithOpts("testdb", DBOptions{})
 	info, err := db.Info()
 	if err != nil {
 		t.Fatalf("Info() returned error: %s", err)
@@ -72,7 +83,7 @@ func TestPutGet(t *testing.T) {
 		t.Fatalf("Error calling Put(): %s", err)
 	}
 	var got map[string]interface{}
-	err = db.Get("foobar", &got, Options{})
+	err = db.Get("foobar", &got, GetOptions{})
 	if err != nil {
 		t.Fatalf("Error calling Get(): %s", err)
 	}
@@ -95,18 +106,6 @@ func TestPutGet(t *testing.T) {
 	db.Destroy(Options{})
 }
 
-type TestRow struct {
-	Id  string  `json:"id"`
-	Key string  `json:"key"`
-	Doc TestDoc `json:"doc"`
-}
-
-type TestDocCollection struct {
-	TotalRows int       `json:"total_rows"`
-	Offset    int       `json:"offset"`
-	Rows      []TestRow `json:"rows"`
-}
-
 func TestBulkDocs(t *testing.T) {
 	db := newPouch("testdb")
 	docs := []TestDoc{
@@ -119,7 +118,7 @@ func TestBulkDocs(t *testing.T) {
 			Value: "bar",
 		},
 	}
-	results, err := db.BulkDocs(docs, Options{})
+	results, err := db.BulkDocs(docs, BulkDocsOptions{})
 	if err != nil {
 		t.Fatalf("Received error from BulkDocs: %s", err)
 	}
@@ -132,21 +131,48 @@ func TestBulkDocs(t *testing.T) {
 		}
 	}
 	// test AllDocs()
-	allDocs := TestDocCollection{}
-	db.AllDocs(&allDocs, Options{
+	allDocs, err := db.AllDocs(AllDocsOptions{
 		IncludeDocs: true,
 	})
+	if err != nil {
+		t.Fatalf("Received error from AllDocs: %s", err)
+	}
 	if allDocs.TotalRows != 2 {
 		t.Fatalf("Got an unexpected number of results: %d", allDocs.TotalRows)
 	}
 	if allDocs.Offset != 0 {
 		t.Fatalf("Got an unexpected offset: %d", allDocs.Offset)
 	}
-	for _, row := range allDocs.Rows {
-		doc := row.Doc
+	for allDocs.Rows.Next() {
+		row := allDocs.Rows.Row()
+		var doc TestDoc
+		if err := row.ScanDoc(&doc); err != nil {
+			t.Fatalf("Error scanning doc: %s", err)
+		}
 		if doc.DocId != "foo" && doc.DocId != "bar" {
 			t.Fatalf("Unexpected _id in result set: %s", doc.DocId)
 		}
+		if doc.DocId != row.ID {
+			t.Fatalf("Row ID %s does not match doc _id %s", row.ID, doc.DocId)
+		}
+	}
+	// missing keys are reported as row errors
+	allDocs, err = db.AllDocs(AllDocsOptions{
+		Keys: []string{"foo", "missing"},
+	})
+	if err != nil {
+		t.Fatalf("Received error from AllDocs: %s", err)
+	}
+	if n := allDocs.Rows.Len(); n != 2 {
+		t.Fatalf("Got an unexpected number of rows: %d", n)
+	}
+	allDocs.Rows.Next()
+	if err := allDocs.Rows.Row().Error; err != nil {
+		t.Errorf("Unexpected error for existing doc: %s", err)
+	}
+	allDocs.Rows.Next()
+	if err := allDocs.Rows.Row().Error; !IsNotExist(err) {
+		t.Errorf("Expected not found error for missing doc, got %v", err)
 	}
 	db.Destroy(Options{})
 }
@@ -166,7 +192,7 @@ func TestRemove(t *testing.T) {
 		t.Fatalf("Received error from Delete: %s", err)
 	}
 	var deletedDoc TestDoc
-	err = db.Get("foo", &deletedDoc, Options{Rev: delRev})
+	err = db.Get("foo", &deletedDoc, GetOptions{Rev: delRev})
 	if err != nil {
 		t.Fatalf("Error fetching deleted doc: %s", err)
 	}
@@ -187,7 +213,7 @@ func TestViewCleanup(t *testing.T) {
 
 func TestCompact(t *testing.T) {
 	db := newPouch("testdb")
-	err := db.Compact(Options{})
+	err := db.Compact(CompactOptions{})
 	if err != nil {
 		t.Fatalf("Error compacting database: %s", err)
 	}
@@ -200,7 +226,7 @@ func TestAttachments(t *testing.T) {
 	att1 := &Attachment{
 		Name: "foo.txt",
 		Type: "text/plain",
-		Body: strings.NewReader(body1),
+		Body: ioutil.NopCloser(strings.NewReader(body1)),
 	}
 	rev, err := db.PutAttachment("foo", att1, "")
 	if err != nil {
@@ -210,8 +236,12 @@ func TestAttachments(t *testing.T) {
 		t.Fatal("PutAttachment() returned a 0-byte rev")
 	}
 	att2, err := db.Attachment("foo", "foo.txt", "")
+	if err != nil {
+		t.Fatalf("Error fetching attachment: %s", err)
+	}
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(att2.Body)
+	att2.Close()
 	body2 := buf.String()
 	if body1 != body2 {
 		t.Fatalf("The fetched body doesn't match. Got '%s' instead of '%s'", body2, body1)
@@ -226,6 +256,277 @@ func TestAttachments(t *testing.T) {
 	db.Destroy(Options{})
 }
 
+func TestBinaryAttachments(t *testing.T) {
+	db := newPouch("testdb")
+	rnd := rand.New(rand.NewSource(1))
+	var rev string
+	for i, size := range []int{1, 256, 65536} {
+		body := make([]byte, size)
+		rnd.Read(body)
+		// Ensure sequences which are invalid as UTF-8 are included
+		body[0] = 0xff
+		name := fmt.Sprintf("blob%d.bin", i)
+		var err error
+		rev, err = db.PutAttachment("binary", &Attachment{
+			Name: name,
+			Type: "application/octet-stream",
+			Body: ioutil.NopCloser(bytes.NewReader(body)),
+		}, rev)
+		if err != nil {
+			t.Fatalf("Error putting attachment %s: %s", name, err)
+		}
+		att, err := db.Attachment("binary", name, "")
+		if err != nil {
+			t.Fatalf("Error fetching attachment %s: %s", name, err)
+		}
+		got, err := ioutil.ReadAll(att.Body)
+		att.Close()
+		if err != nil {
+			t.Fatalf("Error reading attachment %s: %s", name, err)
+		}
+		if !bytes.Equal(got, body) {
+			t.Errorf("Attachment %s did not round-trip: got %d bytes, expected %d", name, len(got), len(body))
+		}
+	}
+	db.Destroy(Options{})
+}
+
+func TestAttachmentMetadata(t *testing.T) {
+	db := newPouch("testdb")
+	body := "Hello, world!"
+	rev, err := db.PutAttachment("meta", &Attachment{
+		Name: "b.txt",
+		Type: "text/plain",
+		Body: ioutil.NopCloser(strings.NewReader(body)),
+	}, "")
+	if err != nil {
+		t.Fatalf("Error putting attachment: %s", err)
+	}
+	rev, err = db.PutAttachment("meta", &Attachment{
+		Name: "a.json",
+		Type: "application/json",
+		Body: ioutil.NopCloser(strings.NewReader("{}")),
+	}, rev)
+	if err != nil {
+		t.Fatalf("Error putting attachment: %s", err)
+	}
+	stubs, err := db.Attachments("meta", "")
+	if err != nil {
+		t.Fatalf("Error listing attachments: %s", err)
+	}
+	if len(stubs) != 2 {
+		t.Fatalf("Expected 2 stubs, got %d", len(stubs))
+	}
+	if stubs[0].Name != "a.json" || stubs[1].Name != "b.txt" {
+		t.Errorf("Unexpected stub order: %s, %s", stubs[0].Name, stubs[1].Name)
+	}
+	sum := md5.Sum([]byte(body))
+	expected := &AttachmentStub{
+		Name:        "b.txt",
+		ContentType: "text/plain",
+		Digest:      "md5-" + base64.StdEncoding.EncodeToString(sum[:]),
+		Length:      int64(len(body)),
+		RevPos:      1,
+	}
+	if !reflect.DeepEqual(expected, stubs[1]) {
+		t.Errorf("Unexpected stub %+v, expected %+v", stubs[1], expected)
+	}
+	att, err := db.AttachmentWithOpts("meta", "b.txt", AttachmentOptions{Verify: true})
+	if err != nil {
+		t.Fatalf("Error fetching attachment: %s", err)
+	}
+	if att.Type != "text/plain" {
+		t.Errorf("Expected type text/plain, got '%s'", att.Type)
+	}
+	if !bytes.Equal(att.MD5, sum[:]) {
+		t.Errorf("Expected MD5 %x, got %x", sum, att.MD5)
+	}
+	if _, err := ioutil.ReadAll(att.Body); err != nil {
+		t.Errorf("Error reading verified body: %s", err)
+	}
+	if err := att.Close(); err != nil {
+		t.Errorf("Error closing attachment: %s", err)
+	}
+	if _, err := db.AttachmentWithOpts("meta", "missing.txt", AttachmentOptions{Verify: true}); !errors.Is(err, ErrNoDigest) {
+		t.Errorf("Expected ErrNoDigest for a missing stub, got %v", err)
+	}
+	db.Destroy(Options{})
+}
+
+func TestDigestBody(t *testing.T) {
+	body := []byte("Hello, world!")
+	sum := md5.Sum(body)
+	digest := "md5-" + base64.StdEncoding.EncodeToString(sum[:])
+	read := func(body []byte) error {
+		r, err := newDigestBody(ioutil.NopCloser(bytes.NewReader(body)), digest)
+		if err != nil {
+			return err
+		}
+		_, err = ioutil.ReadAll(r)
+		return err
+	}
+	if err := read(body); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if err := read([]byte("Goodbye")); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Expected ErrDigestMismatch, got %v", err)
+	}
+	if _, err := newDigestBody(ioutil.NopCloser(bytes.NewReader(body)), "sha1-abc"); err == nil {
+		t.Errorf("Expected unsupported digest error")
+	}
+}
+
+func TestStreamingAttachments(t *testing.T) {
+	db := newPouch("testdb")
+	body := bytes.Repeat([]byte("0123456789abcdef"), attachmentChunkSize/8+3)
+	// Hide the Seeker, to exercise the chunked path too
+	for i, r := range []io.ReadCloser{newAttachmentData(body), ioutil.NopCloser(bytes.NewReader(body))} {
+		docid := fmt.Sprintf("stream%d", i)
+		_, err := db.PutAttachment(docid, &Attachment{
+			Name: "big.bin",
+			Type: "application/octet-stream",
+			Body: r,
+		}, "")
+		if err != nil {
+			t.Fatalf("Error putting attachment: %s", err)
+		}
+		att, err := db.AttachmentWithOpts(docid, "big.bin", AttachmentOptions{Verify: true})
+		if err != nil {
+			t.Fatalf("Error fetching attachment: %s", err)
+		}
+		got, err := ioutil.ReadAll(iotest.OneByteReader(io.LimitReader(att.Body, 10)))
+		if err != nil || string(got) != "0123456789" {
+			t.Errorf("Unexpected partial read %q, %v", got, err)
+		}
+		rest, err := ioutil.ReadAll(att.Body)
+		if err != nil {
+			t.Fatalf("Error reading attachment: %s", err)
+		}
+		if !bytes.Equal(append(got, rest...), body) {
+			t.Errorf("Attachment did not round-trip")
+		}
+		if err := att.Close(); err != nil {
+			t.Errorf("Error closing attachment: %s", err)
+		}
+		if _, err := att.Body.Read(make([]byte, 1)); err == nil {
+			t.Errorf("Expected an error reading a closed body")
+		}
+	}
+	db.Destroy(Options{})
+}
+
+type TestAttachmentDoc struct {
+	DocId       string      `json:"_id"`
+	DocRev      string      `json:"_rev,omitempty"`
+	Value       string      `json:"foo"`
+	Attachments Attachments `json:"_attachments,omitempty"`
+}
+
+func TestInlineAttachments(t *testing.T) {
+	db := newPouch("testdb")
+	bodies := map[string]string{
+		"a.txt": "Hello, world!",
+		"b.bin": "\xff\x00\xfe",
+	}
+	doc := TestAttachmentDoc{
+		DocId: "inline",
+		Value: "bar",
+		Attachments: Attachments{
+			"a.txt": {Type: "text/plain", Body: ioutil.NopCloser(strings.NewReader(bodies["a.txt"]))},
+			"b.bin": {Type: "application/octet-stream", Body: ioutil.NopCloser(strings.NewReader(bodies["b.bin"]))},
+		},
+	}
+	rev, err := db.Put(doc)
+	if err != nil {
+		t.Fatalf("Error putting document with attachments: %s", err)
+	}
+	if !strings.HasPrefix(rev, "1-") {
+		t.Errorf("Expected a single revision, got %s", rev)
+	}
+
+	var full TestAttachmentDoc
+	if err := db.Get("inline", &full, GetOptions{Attachments: true}); err != nil {
+		t.Fatalf("Error fetching document: %s", err)
+	}
+	if len(full.Attachments) != len(bodies) {
+		t.Fatalf("Expected %d attachments, got %d", len(bodies), len(full.Attachments))
+	}
+	for name, body := range bodies {
+		att := full.Attachments[name]
+		if att == nil || att.Body == nil {
+			t.Fatalf("Attachment %s missing its body", name)
+		}
+		got, _ := ioutil.ReadAll(att.Body)
+		if string(got) != body {
+			t.Errorf("Attachment %s: got %q, expected %q", name, got, body)
+		}
+		sum := md5.Sum([]byte(body))
+		if !bytes.Equal(att.MD5, sum[:]) {
+			t.Errorf("Attachment %s: expected MD5 %x, got %x", name, sum, att.MD5)
+		}
+	}
+
+	// Without the Attachments option, only stubs are returned, and writing
+	// them back leaves the attachments untouched.
+	var stubbed TestAttachmentDoc
+	if err := db.Get("inline", &stubbed, GetOptions{}); err != nil {
+		t.Fatalf("Error fetching document: %s", err)
+	}
+	if att := stubbed.Attachments["a.txt"]; att == nil || att.Body != nil || att.Type != "text/plain" {
+		t.Errorf("Expected a stub for a.txt, got %+v", att)
+	}
+	stubbed.Value = "baz"
+	if _, err := db.Put(stubbed); err != nil {
+		t.Fatalf("Error updating document: %s", err)
+	}
+	att, err := db.Attachment("inline", "a.txt", "")
+	if err != nil {
+		t.Fatalf("Error fetching attachment after update: %s", err)
+	}
+	got, _ := ioutil.ReadAll(att.Body)
+	att.Close()
+	if string(got) != bodies["a.txt"] {
+		t.Errorf("Attachment changed by update: got %q", got)
+	}
+	db.Destroy(Options{})
+}
+
+func TestAttachmentsMarshalJSON(t *testing.T) {
+	atts := Attachments{
+		"a.txt": {Type: "text/plain", Body: ioutil.NopCloser(strings.NewReader("Hello, world!"))},
+	}
+	first, err := json.Marshal(atts)
+	if err != nil {
+		t.Fatalf("Error marshalling attachments: %s", err)
+	}
+	// The Body was consumed, but the same data must be sent again.
+	second, err := json.Marshal(atts)
+	if err != nil {
+		t.Fatalf("Error marshalling attachments again: %s", err)
+	}
+	if string(first) != string(second) {
+		t.Errorf("Marshalled attachments changed: %s, then %s", first, second)
+	}
+	got, _ := ioutil.ReadAll(atts["a.txt"].Body)
+	if string(got) != "Hello, world!" {
+		t.Errorf("Unexpected body after marshalling: %q", got)
+	}
+
+	sum := md5.Sum([]byte("Hello, world!"))
+	stubs := Attachments{"a.txt": {Type: "text/plain", MD5: sum[:], length: 13}}
+	stub, err := json.Marshal(stubs)
+	if err != nil {
+		t.Fatalf("Error marshalling stub: %s", err)
+	}
+	expected := `{"a.txt":{"content_type":"text/plain","digest":"` + formatDigest(sum[:]) + `","length":13,"stub":true}}`
+	if string(stub) != expected {
+		t.Errorf("Unexpected stub\n\tExpected: %s\n\t  Actual: %s", expected, stub)
+	}
+	if _, err := json.Marshal(Attachments{"a.txt": {Type: "text/plain"}}); err == nil {
+		t.Errorf("Expected an error marshalling a stub without a digest")
+	}
+}
+
 func TestReplicate(t *testing.T) {
 	newPouch("db1").Destroy(Options{})
 	newPouch("db2").Destroy(Options{})
@@ -238,12 +539,12 @@ func TestReplicate(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error putting document: %s", err)
 	}
-	err = db1.Get(doc1.DocId, &doc1, Options{})
+	err = db1.Get(doc1.DocId, &doc1, GetOptions{})
 	if err != nil {
 		t.Fatalf("Error re-reading doc1: %s", err)
 	}
 	db2 := newPouch("db2")
-	results, err := Replicate(db1, db2, Options{})
+	results, err := Replicate(db1, db2, ReplicateOptions{})
 	if err != nil {
 		t.Fatalf("Error replicating: %s", err)
 	}
@@ -257,7 +558,7 @@ func TestReplicate(t *testing.T) {
 		t.Fatalf("Unexpected number of failures: %d", x)
 	}
 	doc2 := TestDoc{}
-	db2.Get("oink", &doc2, Options{})
+	db2.Get("oink", &doc2, GetOptions{})
 	if !reflect.DeepEqual(doc1, doc2) {
 		t.Fatalf("Document is different after replication")
 	}
@@ -265,6 +566,28 @@ func TestReplicate(t *testing.T) {
 	db2.Destroy(Options{})
 }
 
+func TestReplicateIgnoresLive(t *testing.T) {
+	newPouch("db1").Destroy(Options{})
+	newPouch("db2").Destroy(Options{})
+	db1 := newPouch("db1")
+	db2 := newPouch("db2")
+	defer db1.Destroy(Options{})
+	defer db2.Destroy(Options{})
+	if _, err := db1.Put(TestDoc{DocId: "oink", Value: "foo"}); err != nil {
+		t.Fatalf("Error putting document: %s", err)
+	}
+	// A live replication would never complete, and time out instead
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	results, err := ReplicateContext(ctx, db1, db2, ReplicateOptions{Live: true, Retry: true})
+	if err != nil {
+		t.Fatalf("Error replicating: %s", err)
+	}
+	if x := int(results["docs_written"].(float64)); x != 1 {
+		t.Errorf("Unexpected number of docs written: %d", x)
+	}
+}
+
 type Event struct {
 	Event  string
 	DbName string
@@ -300,3 +623,101 @@ func TestEvents(t *testing.T) {
 	}
 	close(eventsCh)
 }
+
+func TestRevsDiff(t *testing.T) {
+	db := newPouch("testdb")
+	rev, err := db.Put(TestDoc{DocId: "foo"})
+	if err != nil {
+		t.Fatalf("Error calling Put(): %s", err)
+	}
+	missingRev := "2-7051cbe5c8faecd085a3fa619e6e6337"
+	diff, err := db.RevsDiff(map[string][]string{
+		"foo": []string{rev, missingRev},
+		"bar": []string{"1-abc"},
+	})
+	if err != nil {
+		t.Fatalf("Error calling RevsDiff(): %s", err)
+	}
+	expected := map[string]RevsDiffResult{
+		"foo": RevsDiffResult{Missing: []string{missingRev}},
+		"bar": RevsDiffResult{Missing: []string{"1-abc"}},
+	}
+	for id, exp := range expected {
+		if !reflect.DeepEqual(diff[id].Missing, exp.Missing) {
+			t.Errorf("Got %v missing for %s, expected %v", diff[id].Missing, id, exp.Missing)
+		}
+	}
+	db.Destroy(Options{})
+}
+
+func TestBulkGet(t *testing.T) {
+	db := newPouch("testdb")
+	rev, err := db.Put(TestDoc{DocId: "foo", Value: "bar"})
+	if err != nil {
+		t.Fatalf("Error calling Put(): %s", err)
+	}
+	results, err := db.BulkGet([]BulkGetRequest{
+		BulkGetRequest{ID: "foo", Rev: rev},
+		BulkGetRequest{ID: "missing"},
+	}, BulkGetOptions{})
+	if err != nil {
+		t.Fatalf("Error calling BulkGet(): %s", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Unexpected number of results: %d", len(results))
+	}
+	var doc TestDoc
+	if err := results[0].Docs[0].ScanDoc(&doc); err != nil {
+		t.Fatalf("Error scanning doc: %s", err)
+	}
+	if doc.DocRev != rev || doc.Value != "bar" {
+		t.Errorf("Unexpected doc: %v", doc)
+	}
+	if e := results[1].Docs[0].Error; e == nil || e.Name != "not_found" {
+		t.Errorf("Expected not_found error, got %v", e)
+	}
+	db.Destroy(Options{})
+}
+
+func TestQueryCompoundKeys(t *testing.T) {
+	db := newPouch("testdb")
+	ddoc := map[string]interface{}{
+		"_id": "_design/test",
+		"views": map[string]interface{}{
+			"bytype": map[string]interface{}{
+				"map": "function(doc) { if (doc.type) { emit([doc.type, doc.year]); } }",
+			},
+		},
+	}
+	if _, err := db.Put(ddoc); err != nil {
+		t.Fatalf("Error putting design doc: %s", err)
+	}
+	docs := []map[string]interface{}{
+		{"_id": "a", "type": "post", "year": 2014},
+		{"_id": "b", "type": "post", "year": 2015},
+		{"_id": "c", "type": "page", "year": 2015},
+	}
+	if _, err := db.BulkDocs(docs, BulkDocsOptions{}); err != nil {
+		t.Fatalf("Error from BulkDocs: %s", err)
+	}
+	result, err := db.Query("test/bytype", QueryOptions{
+		StartKey: []interface{}{"post", 2015},
+		EndKey:   []interface{}{"post", HighKey},
+	})
+	if err != nil {
+		t.Fatalf("Error from Query: %s", err)
+	}
+	if n := result.Rows.Len(); n != 1 {
+		t.Fatalf("Unexpected number of rows: %d", n)
+	}
+	result.Rows.Next()
+	row := result.Rows.Row()
+	var key []interface{}
+	if err := row.ScanKey(&key); err != nil {
+		t.Fatalf("Error scanning key: %s", err)
+	}
+	if row.ID != "b" || !reflect.DeepEqual(key, []interface{}{"post", float64(2015)}) {
+		t.Errorf("Unexpected row: %s %v", row.ID, key)
+	}
+	db.Destroy(Options{})
+}
@@ -0,0 +1,113 @@
+package pouchdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// StatsValue is the value of a row reduced by the built-in _stats function.
+// When the mapped values are arrays of numbers, the value is a []StatsValue,
+// with the statistics of each element.
+type StatsValue struct {
+	Sum    float64 `json:"sum"`
+	Count  int64   `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	SumSqr float64 `json:"sumsqr"`
+}
+
+// CountValue is the value of a row reduced by the built-in _count function.
+type CountValue int64
+
+// SumValue is the value of a row reduced by the built-in _sum function, when
+// the mapped values are numbers.
+type SumValue float64
+
+// SumsValue is the value of a row reduced by the built-in _sum function, when
+// the mapped values are arrays of numbers. Each element is the sum of the
+// elements at the same index.
+type SumsValue []float64
+
+// ReduceRow is a row of the result of a reduce query, whose Key and Value are
+// decoded as by json.Unmarshal into an interface{}. To decode them into
+// specific types, scan the rows into a slice of a struct type with typed Key
+// and Value fields instead. For queries which are not grouped, the key is
+// always null.
+type ReduceRow struct {
+	Key   interface{} `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// ScanReduceRows decodes the rows of a reduce query, as returned by Query(),
+// QueryFunc() or GoView.Query(), into dest, which must be a pointer to a
+// slice of structs with exported Key and Value fields, such as []ReduceRow
+// or:
+//
+//    var counts []struct {
+//        Key   []string
+//        Value pouchdb.CountValue
+//    }
+//    err := pouchdb.ScanReduceRows(result.Rows, &counts)
+//
+// Each row's key and value are decoded into a new element's Key and Value
+// fields, with Row.ScanKey and Row.ScanValue, which may also be used to
+// decode rows one at a time. Any elements already in the slice are replaced.
+// Nothing is stored in dest if an error is returned.
+func ScanReduceRows(rows *Rows, dest interface{}) error {
+	ptr := reflect.ValueOf(dest)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Slice {
+		return errors.New("dest must be a pointer to a slice")
+	}
+	slice := ptr.Elem()
+	elemType := slice.Type().Elem()
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("dest must be a pointer to a slice of structs, not of %s", elemType)
+	}
+	keyField, hasKey := elemType.FieldByName("Key")
+	valueField, hasValue := elemType.FieldByName("Value")
+	if !hasKey || !hasValue || keyField.PkgPath != "" || valueField.PkgPath != "" {
+		return fmt.Errorf("%s must have exported Key and Value fields", elemType)
+	}
+	result := reflect.MakeSlice(slice.Type(), 0, rows.Len())
+	for rows.Next() {
+		row := rows.Row()
+		if row.Error != nil {
+			return row.Error
+		}
+		elem := reflect.New(elemType).Elem()
+		if err := row.ScanKey(elem.FieldByIndex(keyField.Index).Addr().Interface()); err != nil {
+			return err
+		}
+		if err := row.ScanValue(elem.FieldByIndex(valueField.Index).Addr().Interface()); err != nil {
+			return err
+		}
+		result = reflect.Append(result, elem)
+	}
+	slice.Set(result)
+	return nil
+}
+
+// QueryReduce queries a view, like Query(), and decodes the reduced rows into
+// dest, as ScanReduceRows does. For instance, the number of documents of each
+// type, grouped by a view which emits [type, year] keys and reduces them with
+// _count, may be read with:
+//
+//    var counts []struct {
+//        Key   []string
+//        Value pouchdb.CountValue
+//    }
+//    err := db.QueryReduce("docs/by_type", pouchdb.QueryOptions{GroupLevel: 1}, &counts)
+func (db *PouchDB) QueryReduce(view string, opts QueryOptions, dest interface{}) error {
+	return db.QueryReduceContext(context.Background(), view, opts, dest)
+}
+
+// QueryReduceContext is like QueryReduce, but accepts a context.
+func (db *PouchDB) QueryReduceContext(ctx context.Context, view string, opts QueryOptions, dest interface{}) error {
+	result, err := db.QueryContext(ctx, view, opts)
+	if err != nil {
+		return err
+	}
+	return ScanReduceRows(result.Rows, dest)
+}
@@ -0,0 +1,142 @@
+package pouchdb
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestQueryReduceBuiltins(t *testing.T) {
+	db := newPouch("testdb")
+	defer db.Destroy(Options{})
+	docs := []map[string]interface{}{
+		{"_id": "a", "type": "post", "words": 100, "counts": []int{1, 2}},
+		{"_id": "b", "type": "post", "words": 300, "counts": []int{3, 4}},
+		{"_id": "c", "type": "page", "words": 50, "counts": []int{5, 6}},
+	}
+	if _, err := db.BulkDocs(docs, BulkDocsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.PutDesignDoc(&DesignDoc{
+		ID: "stats",
+		Views: map[string]*View{
+			"count":  {Map: "function(doc) { emit(doc.type); }", Reduce: "_count"},
+			"sum":    {Map: "function(doc) { emit(doc.type, doc.words); }", Reduce: "_sum"},
+			"sums":   {Map: "function(doc) { emit(doc.type, doc.counts); }", Reduce: "_sum"},
+			"stats":  {Map: "function(doc) { emit(doc.type, doc.words); }", Reduce: "_stats"},
+			"nested": {Map: "function(doc) { emit([doc.type, doc._id], doc.words); }", Reduce: "_count"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grouped := QueryOptions{Group: true}
+
+	var counts []struct {
+		Key   string
+		Value CountValue
+	}
+	if err := db.QueryReduce("stats/count", grouped, &counts); err != nil {
+		t.Fatalf("Error from QueryReduce: %s", err)
+	}
+	if len(counts) != 2 || counts[0].Key != "page" || counts[0].Value != 1 || counts[1].Key != "post" || counts[1].Value != 2 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+
+	var sums []struct {
+		Key   string
+		Value SumValue
+	}
+	if err := db.QueryReduce("stats/sum", grouped, &sums); err != nil {
+		t.Fatalf("Error from QueryReduce: %s", err)
+	}
+	if len(sums) != 2 || sums[0].Value != 50 || sums[1].Value != 400 {
+		t.Errorf("Unexpected sums: %v", sums)
+	}
+
+	var arraySums []ReduceRow
+	if err := db.QueryReduce("stats/sums", QueryOptions{}, &arraySums); err != nil {
+		t.Fatalf("Error from QueryReduce: %s", err)
+	}
+	if expected := []ReduceRow{{nil, []interface{}{9.0, 12.0}}}; !reflect.DeepEqual(expected, arraySums) {
+		t.Errorf("Unexpected array sums: %v", arraySums)
+	}
+
+	var stats []struct {
+		Key   string
+		Value StatsValue
+	}
+	if err := db.QueryReduce("stats/stats", QueryOptions{Key: "post"}, &stats); err != nil {
+		t.Fatalf("Error from QueryReduce: %s", err)
+	}
+	expectedStats := StatsValue{Sum: 400, Count: 2, Min: 100, Max: 300, SumSqr: 100000}
+	if len(stats) != 1 || stats[0].Key != "post" || stats[0].Value != expectedStats {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+
+	var levels []struct {
+		Key   []string
+		Value CountValue
+	}
+	if err := db.QueryReduce("stats/nested", QueryOptions{GroupLevel: 1}, &levels); err != nil {
+		t.Fatalf("Error from QueryReduce: %s", err)
+	}
+	if len(levels) != 2 || !reflect.DeepEqual(levels[1].Key, []string{"post"}) || levels[1].Value != 2 {
+		t.Errorf("Unexpected group level counts: %v", levels)
+	}
+}
+
+func TestScanReduceRows(t *testing.T) {
+	rows := &Rows{rows: []*Row{
+		{Key: json.RawMessage(`["post"]`), Value: json.RawMessage(`{"sum":3,"count":2,"min":1,"max":2,"sumsqr":5}`)},
+	}}
+	var result []struct {
+		Key   []string
+		Value StatsValue
+	}
+	if err := ScanReduceRows(rows, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 row, got %d", len(result))
+	}
+	if !reflect.DeepEqual(result[0].Key, []string{"post"}) {
+		t.Errorf("Unexpected key: %v", result[0].Key)
+	}
+	expected := StatsValue{Sum: 3, Count: 2, Min: 1, Max: 2, SumSqr: 5}
+	if result[0].Value != expected {
+		t.Errorf("Unexpected value: %+v", result[0].Value)
+	}
+
+	rows = &Rows{rows: []*Row{{Key: json.RawMessage(`null`), Value: json.RawMessage(`4`)}}}
+	var generic []ReduceRow
+	if err := ScanReduceRows(rows, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generic, []ReduceRow{{Key: nil, Value: float64(4)}}) {
+		t.Errorf("Unexpected rows: %+v", generic)
+	}
+
+	rows = &Rows{rows: []*Row{{Key: json.RawMessage(`"x"`), Value: json.RawMessage(`"not a number"`)}}}
+	var counts []struct {
+		Key   string
+		Value CountValue
+	}
+	if err := ScanReduceRows(rows, &counts); err == nil {
+		t.Error("Expected a decoding error")
+	}
+	if counts != nil {
+		t.Errorf("Expected dest to be left unchanged, got %+v", counts)
+	}
+
+	for _, dest := range []interface{}{
+		counts,
+		&[]CountValue{},
+		&[]struct{ Key string }{},
+		&[]struct{ key, value string }{},
+	} {
+		if err := ScanReduceRows(&Rows{}, dest); err == nil {
+			t.Errorf("Expected an error scanning into %T", dest)
+		}
+	}
+}
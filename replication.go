@@ -0,0 +1,372 @@
+package pouchdb
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// ReplicationEventType identifies the kind of a ReplicationEvent.
+type ReplicationEventType string
+
+// The event types emitted by a live replication.
+const (
+	// ReplicationChange is emitted when a batch of changes has been
+	// replicated. Info describes the batch.
+	ReplicationChange ReplicationEventType = "change"
+	// ReplicationPaused is emitted when replication is paused, either
+	// because it has caught up, or because the connection was lost. In the
+	// latter case, Err is set.
+	ReplicationPaused ReplicationEventType = "paused"
+	// ReplicationActive is emitted when replication resumes.
+	ReplicationActive ReplicationEventType = "active"
+	// ReplicationDenied is emitted when a document failed to replicate, for
+	// example due to a validation function or insufficient permissions. Err
+	// describes the failure.
+	ReplicationDenied ReplicationEventType = "denied"
+	// ReplicationComplete is emitted when replication has finished or been
+	// cancelled. Info contains the final replication statistics.
+	ReplicationComplete ReplicationEventType = "complete"
+	// ReplicationError is emitted when replication is stopped by an
+	// unrecoverable error, described by Err.
+	ReplicationError ReplicationEventType = "error"
+)
+
+// ReplicationInfo contains the replication statistics reported by PouchDB
+// with change and complete events.
+type ReplicationInfo struct {
+	OK               bool          `json:"ok"`
+	Status           string        `json:"status"`
+	DocsRead         int           `json:"docs_read"`
+	DocsWritten      int           `json:"docs_written"`
+	DocWriteFailures int           `json:"doc_write_failures"`
+	Errors           []interface{} `json:"errors"`
+	LastSeq          interface{}   `json:"last_seq"`
+	StartTime        time.Time     `json:"start_time"`
+	EndTime          time.Time     `json:"end_time"`
+	// Docs contains the raw documents replicated in this batch. It is only
+	// populated for change events.
+	Docs []json.RawMessage `json:"docs"`
+}
+
+// The directions reported by the events of a Sync replication.
+const (
+	SyncPush = "push"
+	SyncPull = "pull"
+)
+
+// ReplicationEvent represents a single event emitted by a live replication.
+type ReplicationEvent struct {
+	Type ReplicationEventType
+	// Direction is SyncPush or SyncPull for change events emitted by Sync,
+	// and empty otherwise.
+	Direction string
+	Info      *ReplicationInfo
+	Err       error
+}
+
+// Replication is a handle to a replication running in the background.
+//
+// Events are queued until they are read with Next, so long-running
+// replications should be observed by a Go routine looping over Next.
+type Replication struct {
+	ctx    context.Context
+	repl   *js.Object
+	q      *eventQueue
+	event  *ReplicationEvent
+	done   chan struct{}
+	result *ReplicationInfo
+	err    error
+}
+
+// ReplicateLive will replicate data from source to target in the background,
+// continuing to replicate future changes until cancelled, regardless of
+// opts.Live. For one-shot
+// replication in the foreground, use Replicate().
+//
+// See: https://pouchdb.com/api.html#replication
+func ReplicateLive(source, target *PouchDB, opts ReplicateOptions) *Replication {
+	return ReplicateLiveContext(context.Background(), source, target, opts)
+}
+
+// ReplicateLiveContext is like ReplicateLive, but accepts a context.
+// Cancelling ctx cancels the replication, in which case Wait returns
+// ctx.Err().
+func ReplicateLiveContext(ctx context.Context, source, target *PouchDB, opts ReplicateOptions) *Replication {
+	o := opts.compile()
+	o["live"] = true
+	r := newReplication(ctx, globalPouch().Call("replicate", source, target, o))
+	go cancelOnDone(ctx, r.done, r.Cancel)
+	return r
+}
+
+// cancelOnDone calls cancel if ctx is cancelled before done is closed.
+func cancelOnDone(ctx context.Context, done <-chan struct{}, cancel func()) {
+	select {
+	case <-ctx.Done():
+		cancel()
+	case <-done:
+	}
+}
+
+func newReplication(ctx context.Context, repl *js.Object) *Replication {
+	r := &Replication{
+		ctx:  ctx,
+		repl: repl,
+		q:    newEventQueue(),
+		done: make(chan struct{}),
+	}
+	listenReplication(repl, r.q, func(typ ReplicationEventType, o *js.Object) *ReplicationEvent {
+		ev := &ReplicationEvent{}
+		switch typ {
+		case ReplicationChange:
+			ev.Info, ev.Err = replicationInfo(o)
+		case ReplicationComplete:
+			ev.Info, ev.Err = replicationInfo(o)
+			r.finish(ev.Info, ev.Err)
+		case ReplicationError:
+			ev.Err = jsError(o)
+			r.finish(nil, ev.Err)
+		default:
+			ev.Err = jsError(o)
+		}
+		return ev
+	})
+	return r
+}
+
+var replicationEventTypes = []ReplicationEventType{
+	ReplicationChange,
+	ReplicationPaused,
+	ReplicationActive,
+	ReplicationDenied,
+	ReplicationComplete,
+	ReplicationError,
+}
+
+// listenReplication registers listeners for all replication events emitted by
+// the PouchDB replication or sync object repl. Each event's argument is
+// converted with fn, and the result is queued to q. The queue is closed after
+// a complete or error event.
+func listenReplication(repl *js.Object, q *eventQueue, fn func(ReplicationEventType, *js.Object) *ReplicationEvent) {
+	for _, typ := range replicationEventTypes {
+		typ := typ
+		repl.Call("on", string(typ), func(o *js.Object) {
+			ev := fn(typ, o)
+			ev.Type = typ
+			q.push(ev)
+			if typ == ReplicationComplete || typ == ReplicationError {
+				q.close(nil)
+			}
+		})
+	}
+}
+
+func (r *Replication) finish(info *ReplicationInfo, err error) {
+	select {
+	case <-r.done:
+		// Already finished
+		return
+	default:
+	}
+	if err == nil {
+		err = r.ctx.Err()
+	}
+	r.result = info
+	r.err = err
+	close(r.done)
+}
+
+func replicationInfo(o *js.Object) (*ReplicationInfo, error) {
+	if o == nil || o == js.Undefined {
+		return nil, nil
+	}
+	info := &ReplicationInfo{}
+	if err := ConvertJSObject(o, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// jsError converts an error object passed to an event listener to a
+// PouchError, or nil if no error was passed.
+func jsError(o *js.Object) error {
+	if o == nil || o == js.Undefined {
+		return nil
+	}
+	return NewPouchError(&js.Error{Object: o})
+}
+
+// Next prepares the next replication event for reading with the Event method.
+// It blocks until an event is available, and returns false once the
+// replication has completed and all events have been read.
+func (r *Replication) Next() bool {
+	item, err := r.q.pop(context.Background())
+	if err != nil {
+		r.event = nil
+		return false
+	}
+	r.event = item.(*ReplicationEvent)
+	return true
+}
+
+// Event returns the current event, as prepared by Next.
+func (r *Replication) Event() *ReplicationEvent {
+	return r.event
+}
+
+// Cancel stops the replication. A ReplicationComplete event will follow.
+func (r *Replication) Cancel() {
+	r.repl.Call("cancel")
+}
+
+// Wait blocks until the replication has completed, either because it was
+// cancelled or because of an error, and returns the final replication
+// statistics.
+func (r *Replication) Wait() (*ReplicationInfo, error) {
+	<-r.done
+	return r.result, r.err
+}
+
+// SyncInfo contains the final statistics of a Sync replication, for each
+// direction.
+type SyncInfo struct {
+	Push *ReplicationInfo `json:"push"`
+	Pull *ReplicationInfo `json:"pull"`
+}
+
+// SyncReplication is a handle to a bidirectional replication running in the
+// background. Push and pull progress is reported separately, but the two
+// directions are started, cancelled and waited for as a unit.
+//
+// As with Replication, events are queued until they are read with Next.
+type SyncReplication struct {
+	ctx    context.Context
+	sync   *js.Object
+	q      *eventQueue
+	event  *ReplicationEvent
+	mu     sync.Mutex
+	push   *ReplicationInfo
+	pull   *ReplicationInfo
+	done   chan struct{}
+	result *SyncInfo
+	err    error
+}
+
+// Sync data from source to target and target to source. This is a convenience
+// method for bidirectional data replication. Set opts.Live to keep both
+// directions running until cancelled, and opts.Retry to retry after network
+// failures. For foreground replication, call Wait on the returned handle.
+//
+// See: https://pouchdb.com/api.html#sync
+func Sync(source, target *PouchDB, opts ReplicateOptions) *SyncReplication {
+	return SyncContext(context.Background(), source, target, opts)
+}
+
+// SyncContext is like Sync, but accepts a context. Cancelling ctx cancels
+// replication in both directions, in which case Wait returns ctx.Err().
+func SyncContext(ctx context.Context, source, target *PouchDB, opts ReplicateOptions) *SyncReplication {
+	s := &SyncReplication{
+		ctx:  ctx,
+		sync: globalPouch().Call("sync", source, target, opts.compile()),
+		q:    newEventQueue(),
+		done: make(chan struct{}),
+	}
+	listenReplication(s.sync, s.q, func(typ ReplicationEventType, o *js.Object) *ReplicationEvent {
+		ev := &ReplicationEvent{}
+		switch typ {
+		case ReplicationChange:
+			ev.Direction = o.Get("direction").String()
+			ev.Info, ev.Err = replicationInfo(o.Get("change"))
+			s.progress(ev.Direction, ev.Info)
+		case ReplicationDenied:
+			ev.Err = jsError(o)
+		case ReplicationComplete:
+			info := &SyncInfo{}
+			if err := ConvertJSObject(o, info); err != nil {
+				s.finish(nil, err)
+				ev.Err = err
+				break
+			}
+			s.finish(info, nil)
+		case ReplicationError:
+			ev.Err = jsError(o)
+			s.finish(nil, ev.Err)
+		default:
+			ev.Err = jsError(o)
+		}
+		return ev
+	})
+	go cancelOnDone(ctx, s.done, s.Cancel)
+	return s
+}
+
+func (s *SyncReplication) progress(direction string, info *ReplicationInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch direction {
+	case SyncPush:
+		s.push = info
+	case SyncPull:
+		s.pull = info
+	}
+}
+
+func (s *SyncReplication) finish(info *SyncInfo, err error) {
+	select {
+	case <-s.done:
+		// Already finished
+		return
+	default:
+	}
+	if err == nil {
+		err = s.ctx.Err()
+	}
+	s.result = info
+	s.err = err
+	close(s.done)
+}
+
+// Progress returns the most recent statistics reported for each direction.
+// Either value may be nil if nothing has been replicated in that direction
+// yet.
+func (s *SyncReplication) Progress() (push, pull *ReplicationInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.push, s.pull
+}
+
+// Next prepares the next replication event for reading with the Event method.
+// It blocks until an event is available, and returns false once the
+// replication has completed and all events have been read.
+func (s *SyncReplication) Next() bool {
+	item, err := s.q.pop(context.Background())
+	if err != nil {
+		s.event = nil
+		return false
+	}
+	s.event = item.(*ReplicationEvent)
+	return true
+}
+
+// Event returns the current event, as prepared by Next.
+func (s *SyncReplication) Event() *ReplicationEvent {
+	return s.event
+}
+
+// Cancel stops replication in both directions. A ReplicationComplete event
+// will follow.
+func (s *SyncReplication) Cancel() {
+	s.sync.Call("cancel")
+}
+
+// Wait blocks until replication in both directions has completed, either
+// because it was cancelled or because of an error, and returns the final
+// statistics.
+func (s *SyncReplication) Wait() (*SyncInfo, error) {
+	<-s.done
+	return s.result, s.err
+}
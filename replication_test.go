@@ -0,0 +1,108 @@
+package pouchdb
+
+import "testing"
+
+func TestReplicateLive(t *testing.T) {
+	newPouch("db1").Destroy(Options{})
+	newPouch("db2").Destroy(Options{})
+	db1 := newPouch("db1")
+	db2 := newPouch("db2")
+	if _, err := db1.Put(TestDoc{DocId: "oink", Value: "foo"}); err != nil {
+		t.Fatalf("Error putting document: %s", err)
+	}
+	repl := ReplicateLive(db1, db2, ReplicateOptions{})
+	var written int
+	for repl.Next() {
+		ev := repl.Event()
+		switch ev.Type {
+		case ReplicationChange:
+			written += ev.Info.DocsWritten
+		case ReplicationPaused:
+			if ev.Err != nil {
+				t.Fatalf("Replication paused with error: %s", ev.Err)
+			}
+			// Caught up; the document should have been replicated by now
+			repl.Cancel()
+		case ReplicationError, ReplicationDenied:
+			t.Fatalf("Unexpected %s event: %s", ev.Type, ev.Err)
+		}
+	}
+	if written != 1 {
+		t.Errorf("Unexpected number of docs written: %d", written)
+	}
+	info, err := repl.Wait()
+	if err != nil {
+		t.Fatalf("Error from Wait(): %s", err)
+	}
+	if info.Status != "cancelled" {
+		t.Errorf("Unexpected final status: %s", info.Status)
+	}
+	doc := TestDoc{}
+	if err := db2.Get("oink", &doc, GetOptions{}); err != nil {
+		t.Fatalf("Error fetching replicated doc: %s", err)
+	}
+	db1.Destroy(Options{})
+	db2.Destroy(Options{})
+}
+
+func TestSync(t *testing.T) {
+	newPouch("db1").Destroy(Options{})
+	newPouch("db2").Destroy(Options{})
+	db1 := newPouch("db1")
+	db2 := newPouch("db2")
+	if _, err := db1.Put(TestDoc{DocId: "oink", Value: "foo"}); err != nil {
+		t.Fatalf("Error putting document: %s", err)
+	}
+	if _, err := db2.Put(TestDoc{DocId: "moo", Value: "bar"}); err != nil {
+		t.Fatalf("Error putting document: %s", err)
+	}
+	info, err := Sync(db1, db2, ReplicateOptions{}).Wait()
+	if err != nil {
+		t.Fatalf("Error syncing: %s", err)
+	}
+	if info.Push == nil || info.Push.DocsWritten != 1 {
+		t.Errorf("Unexpected push result: %v", info.Push)
+	}
+	if info.Pull == nil || info.Pull.DocsWritten != 1 {
+		t.Errorf("Unexpected pull result: %v", info.Pull)
+	}
+	for _, db := range []*PouchDB{db1, db2} {
+		for _, id := range []string{"oink", "moo"} {
+			doc := TestDoc{}
+			if err := db.Get(id, &doc, GetOptions{}); err != nil {
+				t.Errorf("Error fetching '%s' after sync: %s", id, err)
+			}
+		}
+	}
+	db1.Destroy(Options{})
+	db2.Destroy(Options{})
+}
+
+func TestSyncLiveCancel(t *testing.T) {
+	newPouch("db1").Destroy(Options{})
+	newPouch("db2").Destroy(Options{})
+	db1 := newPouch("db1")
+	db2 := newPouch("db2")
+	if _, err := db1.Put(TestDoc{DocId: "oink", Value: "foo"}); err != nil {
+		t.Fatalf("Error putting document: %s", err)
+	}
+	s := Sync(db1, db2, ReplicateOptions{Live: true})
+	for s.Next() {
+		ev := s.Event()
+		if ev.Type == ReplicationChange && ev.Direction != SyncPush {
+			t.Errorf("Unexpected change direction: %s", ev.Direction)
+		}
+		if ev.Type == ReplicationPaused {
+			s.Cancel()
+		}
+	}
+	if _, err := s.Wait(); err != nil {
+		t.Fatalf("Error from Wait(): %s", err)
+	}
+	push, _ := s.Progress()
+	if push == nil || push.DocsWritten != 1 {
+		t.Errorf("Unexpected push progress: %v", push)
+	}
+	db1.Destroy(Options{})
+	db2.Destroy(Options{})
+}
@@ -1,6 +1,8 @@
 package pouchdb
 
 import (
+	"context"
+
 	"github.com/gopherjs/gopherjs/js"
 )
 
@@ -10,18 +12,36 @@ type resultWaiterTuple struct {
 }
 
 type resultWaiter struct {
+	ctx        context.Context
 	resultChan chan *resultWaiterTuple
 }
 
 func NewResultWaiter() *resultWaiter {
+	return NewResultWaiterContext(context.Background())
+}
+
+// NewResultWaiterContext returns a resultWaiter whose Read methods give up
+// waiting, and return ctx.Err(), once ctx is cancelled.
+func NewResultWaiterContext(ctx context.Context) *resultWaiter {
 	return &resultWaiter{
-		make(chan *resultWaiterTuple),
+		ctx: ctx,
+		// Buffered, so that Done never blocks in the JavaScript callback,
+		// even if nobody is waiting for the result anymore.
+		resultChan: make(chan *resultWaiterTuple, 1),
 	}
 }
 
 // Read returns the raw results of a PouchDB callback
 func (rw *resultWaiter) Read() (*js.Object, error) {
-	rawResult := <-rw.resultChan
+	if err := rw.ctx.Err(); err != nil {
+		return nil, err
+	}
+	var rawResult *resultWaiterTuple
+	select {
+	case rawResult = <-rw.resultChan:
+	case <-rw.ctx.Done():
+		return nil, rw.ctx.Err()
+	}
 	if rawResult.err == nil {
 		return rawResult.result, nil
 	}
@@ -53,6 +73,9 @@ func (rw *resultWaiter) ReadResult() (Result, error) {
 
 func (rw *resultWaiter) ReadBulkResults() ([]Result, error) {
 	result, err := rw.Read()
+	if result == nil {
+		return nil, err
+	}
 	results := make([]Result, result.Length())
 	for i := 0; i < result.Length(); i++ {
 		results[i] = result.Index(i).Interface().(map[string]interface{})
@@ -0,0 +1,156 @@
+package pouchdb
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// ViewResult is the result of AllDocs(), Query() or QueryFunc().
+type ViewResult struct {
+	// TotalRows is the total number of rows in the view, before Limit and
+	// Skip were applied. It is 0 for reduce queries.
+	TotalRows int
+	// Offset is the index of the first returned row within the view.
+	Offset int
+	// UpdateSeq is the database sequence the view is current as of. It is
+	// only set if requested with the UpdateSeq option.
+	UpdateSeq interface{}
+	// Rows iterates over the rows of the result.
+	Rows *Rows
+}
+
+// Rows is an iterator over the rows of a ViewResult:
+//
+//    for result.Rows.Next() {
+//        var doc MyDoc
+//        if err := result.Rows.Row().ScanDoc(&doc); err != nil {
+//            return err
+//        }
+//        ...
+//    }
+type Rows struct {
+	rows []*Row
+	row  *Row
+}
+
+// Row is a single row of a ViewResult. The key, value and document are kept
+// in their raw JSON form until they are decoded with the Scan methods.
+type Row struct {
+	// ID is the ID of the document which emitted the row. It is empty for
+	// reduce queries.
+	ID    string
+	Key   json.RawMessage
+	Value json.RawMessage
+	// Doc is the document, if IncludeDocs was set.
+	Doc json.RawMessage
+	// Error is set for rows which could not be fetched, such as rows for
+	// nonexistent documents when fetching AllDocs() by Keys.
+	Error error
+}
+
+type viewResult struct {
+	TotalRows int           `json:"total_rows"`
+	Offset    int           `json:"offset"`
+	UpdateSeq interface{}   `json:"update_seq"`
+	Rows      []jsonViewRow `json:"rows"`
+}
+
+type jsonViewRow struct {
+	ID    string          `json:"id"`
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+	Doc   json.RawMessage `json:"doc"`
+	Error string          `json:"error"`
+	// Reason accompanies Error in some cases
+	Reason string `json:"reason"`
+}
+
+func newViewResult(obj *js.Object) (*ViewResult, error) {
+	var result viewResult
+	if err := ConvertJSObject(obj, &result); err != nil {
+		return nil, err
+	}
+	rows := make([]*Row, len(result.Rows))
+	for i, r := range result.Rows {
+		row := &Row{
+			ID:    r.ID,
+			Key:   r.Key,
+			Value: r.Value,
+			Doc:   r.Doc,
+		}
+		if r.Error != "" {
+			row.Error = errorFromName(r.Error, r.Reason)
+		}
+		rows[i] = row
+	}
+	return &ViewResult{
+		TotalRows: result.TotalRows,
+		Offset:    result.Offset,
+		UpdateSeq: result.UpdateSeq,
+		Rows:      &Rows{rows: rows},
+	}, nil
+}
+
+// errorFromName builds a PouchError from a CouchDB-style error name, such as
+// "not_found", filling in the status from the matching sentinel error.
+func errorFromName(name, reason string) *PouchError {
+	pe := &PouchError{
+		Name:    name,
+		Message: name,
+		Reason:  reason,
+		IsError: true,
+	}
+	for _, sentinel := range []*PouchError{ErrBadRequest, ErrUnauthorized, ErrForbidden, ErrNotFound, ErrConflict, ErrPreconditionFailed, ErrInternalServer} {
+		if sentinel.Name == name {
+			pe.Status = sentinel.Status
+			pe.Message = sentinel.Message
+		}
+	}
+	return pe
+}
+
+// Next advances to the next row, which can then be read with Row. It returns
+// false when there are no more rows.
+func (r *Rows) Next() bool {
+	if len(r.rows) == 0 {
+		r.row = nil
+		return false
+	}
+	r.row = r.rows[0]
+	r.rows = r.rows[1:]
+	return true
+}
+
+// Row returns the current row, as prepared by Next.
+func (r *Rows) Row() *Row {
+	return r.row
+}
+
+// Len returns the number of rows which have not yet been read.
+func (r *Rows) Len() int {
+	return len(r.rows)
+}
+
+// ScanKey unmarshals the row's key into dest.
+func (r *Row) ScanKey(dest interface{}) error {
+	return json.Unmarshal(r.Key, dest)
+}
+
+// ScanValue unmarshals the row's value into dest.
+func (r *Row) ScanValue(dest interface{}) error {
+	return json.Unmarshal(r.Value, dest)
+}
+
+// ScanDoc unmarshals the row's document into dest. If the row represents an
+// error, that error is returned instead.
+func (r *Row) ScanDoc(dest interface{}) error {
+	if r.Error != nil {
+		return r.Error
+	}
+	if len(r.Doc) == 0 || string(r.Doc) == "null" {
+		return errors.New("no document included with row")
+	}
+	return json.Unmarshal(r.Doc, dest)
+}
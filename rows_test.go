@@ -0,0 +1,54 @@
+package pouchdb
+
+import (
+	"testing"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+func TestNewViewResult(t *testing.T) {
+	obj := js.Global.Get("JSON").Call("parse", `{
+		"total_rows": 5,
+		"offset": 1,
+		"update_seq": 12,
+		"rows": [
+			{"id": "a", "key": ["x", 1], "value": {"count": 3}, "doc": {"_id": "a", "foo": "bar"}},
+			{"key": "missing", "error": "not_found"}
+		]
+	}`)
+	result, err := newViewResult(obj)
+	if err != nil {
+		t.Fatalf("Error from newViewResult: %s", err)
+	}
+	if result.TotalRows != 5 || result.Offset != 1 || result.UpdateSeq != float64(12) {
+		t.Errorf("Unexpected result metadata: %v", result)
+	}
+	if !result.Rows.Next() {
+		t.Fatal("Expected a first row")
+	}
+	row := result.Rows.Row()
+	var key []interface{}
+	if err := row.ScanKey(&key); err != nil || len(key) != 2 || key[0] != "x" {
+		t.Errorf("Unexpected key %v (%v)", key, err)
+	}
+	var value struct {
+		Count int `json:"count"`
+	}
+	if err := row.ScanValue(&value); err != nil || value.Count != 3 {
+		t.Errorf("Unexpected value %v (%v)", value, err)
+	}
+	var doc TestDoc
+	if err := row.ScanDoc(&doc); err != nil || doc.DocId != "a" || doc.Value != "bar" {
+		t.Errorf("Unexpected doc %v (%v)", doc, err)
+	}
+	if !result.Rows.Next() {
+		t.Fatal("Expected a second row")
+	}
+	row = result.Rows.Row()
+	if err := row.ScanDoc(&doc); !IsNotExist(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	if result.Rows.Next() {
+		t.Error("Expected only two rows")
+	}
+}